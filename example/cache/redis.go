@@ -6,10 +6,11 @@ import (
 	"log"
 
 	"github.com/aivencs/magic-box/pkg/cache"
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func main() {
-	ctx := context.WithValue(context.Background(), "trace", "ctx-cache-001")
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-001")
 	opt := cache.Option{
 		Host:     "localhost:6379",
 		Auth:     true,
@@ -26,8 +27,8 @@ func main() {
 	payload := "19619c9e08f0ed4cc147e211efa8c3f0"
 	r, err := cache.SetEx(ctx, payload, 1, 20)
 	fmt.Println(r, err) // output: OK nil
-	ov := cache.Overdue(ctx, payload)
-	fmt.Println(ov)                             // output: true
+	ov, err := cache.Overdue(ctx, payload)
+	fmt.Println(ov, err)                        // output: true <nil>
 	fmt.Println(cache.Set(ctx, payload, "105")) // output: OK nil
 	val, err := cache.Get(ctx, payload)
 	fmt.Println(string(val.([]uint8)), err) // output: 105 <nil>
@@ -6,10 +6,11 @@ import (
 	"log"
 
 	"github.com/aivencs/magic-box/pkg/filter"
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func main() {
-	ctx := context.WithValue(context.Background(), "trace", "ctx-filter-001")
+	ctx := trace.WithTrace(context.Background(), "ctx-filter-001")
 	err := filter.InitFilter(ctx, filter.BLOOM_FILTER, filter.Option{
 		Host:     "localhost:6379",
 		Auth:     true,
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/cache"
+	"github.com/aivencs/magic-box/pkg/filter"
+	"github.com/aivencs/magic-box/pkg/lifecycle"
+	"github.com/aivencs/magic-box/pkg/trace"
+)
+
+func main() {
+	ctx := trace.WithTrace(context.Background(), "ctx-lifecycle-001")
+	err := lifecycle.InitLifecycle(ctx, lifecycle.Option{Timeout: 10 * time.Second})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 初始化的对象自动注册，退出时按初始化的逆序关闭
+	err = cache.InitCache(ctx, cache.REDIS, cache.Option{Host: "localhost:6379", DB: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = filter.InitFilter(ctx, filter.BLOOM_FILTER, filter.Option{Host: "localhost:6379", DB: 1, Key: "seeds"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 阻塞至收到SIGTERM或SIGINT，先关闭过滤器再关闭缓存
+	if err := lifecycle.Wait(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/cache"
+	"github.com/aivencs/magic-box/pkg/limiter"
+	"github.com/aivencs/magic-box/pkg/server"
+	"github.com/aivencs/magic-box/pkg/trace"
+	"github.com/labstack/echo/v4"
+)
+
+func main() {
+	ctx := trace.WithTrace(context.Background(), "ctx-limiter-001")
+	err := cache.InitCache(ctx, cache.REDIS, cache.Option{Host: "localhost:6379", DB: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 每个客户端IP每分钟最多100次，多个实例共享计数
+	l, err := limiter.New(ctx, limiter.SLIDING_WINDOW, limiter.Option{
+		Limit:  100,
+		Window: time.Minute,
+		Cache:  cache.Default(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = server.InitServer(ctx, server.SERVER_ECHO, server.Option{Port: 9817, Host: "localhost"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	server.AddRouter(server.RouterPayload{Method: server.GET, Path: "/ping"}, func(c echo.Context) error {
+		return server.Respond(c, "pong", nil)
+	}, server.LoggerNormal, server.RateLimit(l, nil))
+	server.Work()
+}
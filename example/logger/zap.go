@@ -6,6 +6,7 @@ import (
 	"log"
 
 	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func main() {
@@ -15,7 +16,7 @@ func main() {
 	fmt.Println("erc: ", erc)
 
 	/* example for zap logger */
-	ctx := context.WithValue(context.Background(), "trace", "t00")
+	ctx := trace.WithTrace(context.Background(), "t00")
 	// 初始化日志对象
 	err := logger.InitLogger(ctx, logger.Zap, logger.Option{
 		Application: "zap-log",
@@ -26,7 +27,7 @@ func main() {
 		log.Fatal(err)
 	}
 	// 1
-	ctx = context.WithValue(context.Background(), "trace", "t01")
+	ctx = trace.WithTrace(context.Background(), "t01")
 	logger.Info(ctx, logger.Message{Text: "操作失败", Remark: "标题替代正文", Traceback: "按规则未找到正文",
 		Attr: logger.Attr{
 			Inp: map[string]interface{}{"link": "http://localhost:9087"},
@@ -41,7 +42,7 @@ func main() {
 		},
 	})
 	// 2
-	ctx = context.WithValue(context.Background(), "trace", "t02")
+	ctx = trace.WithTrace(context.Background(), "t02")
 	logger.Error(ctx, logger.Message{Text: "work", Remark: "说明", Traceback: "调用超时", Label: "render",
 		Attr: logger.Attr{
 			Inp: map[string]interface{}{"application": "spanic-service-net"},
@@ -7,10 +7,11 @@ import (
 	"time"
 
 	"github.com/aivencs/magic-box/pkg/messenger"
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func main() {
-	ctx := context.WithValue(context.Background(), "trace", "ctx-messenger-001")
+	ctx := trace.WithTrace(context.Background(), "ctx-messenger-001")
 	option := messenger.Option{
 		Host:      "localhost:5672",
 		Auth:      true,
@@ -7,11 +7,12 @@ import (
 
 	"github.com/aivencs/magic-box/pkg/logger"
 	"github.com/aivencs/magic-box/pkg/request"
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func main() {
-	ctx := context.WithValue(context.Background(), "trace", "r001")
-	ctx = context.WithValue(ctx, "label", "request")
+	ctx := trace.WithTrace(context.Background(), "r001")
+	ctx = trace.WithLabel(ctx, "request")
 	for i := 0; i < 2; i++ {
 		initError := request.InitRequest(ctx, "resty", request.Option{
 			LogType: logger.Zap,
@@ -5,10 +5,11 @@ import (
 	"log"
 
 	"github.com/aivencs/magic-box/pkg/server"
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func main() {
-	ctx := context.WithValue(context.Background(), "trace", "v001")
+	ctx := trace.WithTrace(context.Background(), "v001")
 	err := server.InitServer(ctx, server.SERVER_ECHO, server.Option{Port: 9817, Host: "localhost"})
 	if err != nil {
 		log.Fatal(err)
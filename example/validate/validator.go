@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 )
 
@@ -29,7 +30,7 @@ func main() {
 		Email:   "abcfoxmail@foxmail.com",
 		Content: "<a>aps<a>",
 	}
-	ctx := context.WithValue(context.Background(), "trace", "v001")
+	ctx := trace.WithTrace(context.Background(), "v001")
 	validate.InitValidate(ctx, "validator", validate.Option{})
 	fmt.Println(validate.Work(ctx, users))
 }
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 使用枚举限定选择
+type CodecType string
+
+const (
+	JSON    CodecType = "json"    // encoding/json
+	MSGPACK CodecType = "msgpack" // MessagePack
+	GOB     CodecType = "gob"     // encoding/gob
+	// 定义默认值
+	DEFAULT_CODEC = JSON
+)
+
+// 键不存在时类型化读取返回的错误
+var ErrNil = redigo.ErrNil
+
+// 序列化接口，用于写入与读取非基础类型的值
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// 抽象工厂
+func CodecFactory(name CodecType) (Codec, error) {
+	switch name {
+	case JSON, "":
+		return JSONCodec{}, nil
+	case MSGPACK:
+		return MsgpackCodec{}, nil
+	case GOB:
+		return GobCodec{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的序列化方式: %s", name)
+	}
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// 持有序列化方式的缓存对象
+type codecHolder interface {
+	Codec() Codec
+}
+
+// 获取缓存对象所用的序列化方式，未设置时使用json
+func CodecOf(c Cache) Codec {
+	if holder, ok := c.(codecHolder); ok && holder.Codec() != nil {
+		return holder.Codec()
+	}
+	return JSONCodec{}
+}
+
+// 基础类型按Redis的规则写入，其余类型使用序列化方式编码
+func encodeValue(codec Codec, value interface{}) ([]byte, error) {
+	switch value.(type) {
+	case string, []byte, bool, nil, redigo.Argument,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return encodeArg(value), nil
+	default:
+		data, err := codec.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("编码失败: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// 将读取结果解码到 v，基础类型按Redis的规则转换，其余类型使用序列化方式解码
+func decodeValue(codec Codec, reply interface{}, err error, v interface{}) error {
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return ErrNil
+	}
+	switch v := v.(type) {
+	case *string:
+		*v, err = redigo.String(reply, nil)
+	case *[]byte:
+		*v, err = redigo.Bytes(reply, nil)
+	case *int:
+		*v, err = redigo.Int(reply, nil)
+	case *int64:
+		*v, err = redigo.Int64(reply, nil)
+	case *uint64:
+		*v, err = redigo.Uint64(reply, nil)
+	case *float64:
+		*v, err = redigo.Float64(reply, nil)
+	case *bool:
+		*v, err = redigo.Bool(reply, nil)
+	default:
+		data, err := redigo.Bytes(reply, nil)
+		if err != nil {
+			return err
+		}
+		if err := codec.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("解码失败: %w", err)
+		}
+		return nil
+	}
+	return err
+}
@@ -2,12 +2,23 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+	"github.com/alicebob/miniredis/v2"
+	redigo "github.com/gomodule/redigo/redis"
 )
 
 func ExampleRedisCache() {
-	ctx := context.WithValue(context.Background(), "trace", "ctx-cache-001")
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-001")
 	opt := Option{
 		Host:     "localhost:6379",
 		Auth:     true,
@@ -22,11 +33,691 @@ func ExampleRedisCache() {
 		log.Fatal(err)
 	}
 	payload := "19619c9e08f0ed4cc147e211efa8c3f0"
-	r, err := cache.SetEx(ctx, payload, 1, 20)
-	fmt.Println(r, err) // output: OK nil
-	ov := cache.Overdue(ctx, payload)
-	fmt.Println(ov)                             // output: true
-	fmt.Println(cache.Set(ctx, payload, "105")) // output: OK nil
-	val, err := cache.Get(ctx, payload)
-	fmt.Println(string(val.([]uint8)), err) // output: 105 <nil>
+	r, err := SetEx(ctx, payload, 1, 20)
+	fmt.Println(r, err) // 输出: OK nil
+	ov, err := Overdue(ctx, payload)
+	fmt.Println(ov, err)                  // 输出: true <nil>
+	fmt.Println(Set(ctx, payload, "105")) // 输出: OK nil
+	val, err := Get(ctx, payload)
+	fmt.Println(string(val.([]uint8)), err) // 输出: 105 <nil>
+}
+
+func ExampleUse() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-002")
+	// 同时连接两个Redis服务
+	sessions, err := New(ctx, REDIS, Option{Host: "localhost:6379", DB: 2})
+	if err != nil {
+		log.Fatal(err)
+	}
+	Register("sessions", sessions)
+	err = InitCache(ctx, REDIS, Option{Host: "localhost:6380", DB: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(Use("sessions").Set(ctx, "token", "abc")) // 写入sessions
+	fmt.Println(Set(ctx, "token", "abc"))                 // 写入默认对象
+}
+
+// 关闭时等待进行中的任务完成
+type busyCache struct {
+	Cache
+	closing chan struct{}
+	release chan struct{}
+}
+
+func (c *busyCache) Close(ctx context.Context) error {
+	close(c.closing)
+	<-c.release
+	return c.Cache.Close(ctx)
+}
+
+func ExampleRegister() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-019")
+	first, _ := New(ctx, MEMORY, Option{})
+	next, _ := New(ctx, MEMORY, Option{})
+	busy := &busyCache{Cache: first, closing: make(chan struct{}), release: make(chan struct{})}
+	Register(DEFAULT_INSTANCE, busy)
+	// 替换时关闭原对象，关闭期间其他协程仍可获取对象
+	done := make(chan struct{})
+	go func() {
+		Register(DEFAULT_INSTANCE, next)
+		close(done)
+	}()
+	<-busy.closing
+	fmt.Println(Default() == next)
+	close(busy.release)
+	<-done
+	_, err := first.Get(ctx, "name")
+	fmt.Println(err)
+	// Output:
+	// true
+	// 缓存对象已关闭
+}
+
+func ExampleMemoryCache() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-003")
+	c, err := New(ctx, MEMORY, Option{MaxEntries: 2})
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.Set(ctx, "a", 1)
+	c.SetEx(ctx, "b", "2", 20)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", 3.5) // 超出最大键数量，淘汰最久未使用的b
+	for _, key := range []string{"a", "b", "c"} {
+		val, err := redigo.String(c.Get(ctx, key))
+		fmt.Println(key, val, err)
+	}
+	stats := c.(*MemoryCache).Stats()
+	fmt.Println(stats.Hits, stats.Misses, stats.Evictions, stats.Entries)
+	// Output:
+	// a 1 <nil>
+	// b  redigo: nil returned
+	// c 3.5 <nil>
+	// 3 1 1 2
+}
+
+func ExampleTieredCache() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-004")
+	// 热点键优先读取本地内存，其他实例写入或删除时通过Redis通知本地失效
+	err := InitCache(ctx, TIERED, Option{
+		Host:       "localhost:6379",
+		DB:         1,
+		MaxEntries: 1000,
+		LocalTTL:   30 * time.Second,
+		Channel:    "spider:cache:invalidate",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(Set(ctx, "hot", "value"))
+	fmt.Println(redigo.String(Get(ctx, "hot"))) // 读取本地内存
+}
+
+func ExampleTypedCache() {
+	type Article struct {
+		Title string
+		Views int
+	}
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-005")
+	c, err := New(ctx, MEMORY, Option{Codec: MSGPACK})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 非基础类型按序列化方式编码，基础类型保持与Redis一致
+	c.Set(ctx, "article", Article{Title: "magic-box", Views: 3})
+	c.Set(ctx, "views", 3)
+	var article Article
+	err = Typed(c).GetObject(ctx, "article", &article)
+	fmt.Println(article, err)
+	fmt.Println(Typed(c).GetInt(ctx, "views"))
+	_, err = Typed(c).GetString(ctx, "missing")
+	fmt.Println(errors.Is(err, ErrNil))
+	// Output:
+	// {magic-box 3} <nil>
+	// 3 <nil>
+	// true
+}
+
+func ExampleMemoryCache_lifecycle() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-006")
+	c, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(c.SetNX(ctx, "lock", "a", time.Minute))
+	fmt.Println(c.SetNX(ctx, "lock", "b", time.Minute))
+	fmt.Println(c.IncrBy(ctx, "count", 5))
+	fmt.Println(c.Decr(ctx, "count"))
+	c.MSet(ctx, map[string]interface{}{"x": 1, "y": "2"})
+	values, _ := redigo.Strings(c.MGet(ctx, "x", "y", "z"))
+	fmt.Printf("%q\n", values)
+	fmt.Println(c.Expire(ctx, "x", 10*time.Second))
+	// 剩余有效期超过1秒时为 true
+	fmt.Println(c.Overdue(ctx, "x"))
+	ttl, _ := c.TTL(ctx, "x")
+	fmt.Println(ttl > 9*time.Second, ttl <= 10*time.Second)
+	ttl, _ = c.TTL(ctx, "y")
+	fmt.Println(ttl == TTL_PERSISTENT)
+	fmt.Println(c.Delete(ctx, "x", "y", "z"))
+	fmt.Println(c.Exists(ctx, "x"))
+	fmt.Println(c.Overdue(ctx, "x"))
+	// Output:
+	// true <nil>
+	// false <nil>
+	// 5 <nil>
+	// 4 <nil>
+	// ["1" "2" ""]
+	// true <nil>
+	// true <nil>
+	// true true
+	// true
+	// 2 <nil>
+	// false <nil>
+	// false <nil>
+}
+
+func ExampleLoader() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-007")
+	c, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	loader, err := NewLoader(c, LoadOption{NegativeTTL: 5 * time.Second, Jitter: 0.1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	var calls int32
+	query := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return "magic-box", nil
+	}
+	// 并发读取同一个键时只查询一次
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loader.GetOrLoad(ctx, "article:1", time.Minute, query)
+		}()
+	}
+	wg.Wait()
+	fmt.Println(redigo.String(loader.GetOrLoad(ctx, "article:1", time.Minute, query)))
+	fmt.Println(atomic.LoadInt32(&calls))
+	// 未启用过期后可用时写入原值，可直接读取
+	fmt.Println(redigo.String(c.Get(ctx, "article:1")))
+	// 不存在的结果在 NegativeTTL 内不再查询
+	missing := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		_, err = loader.GetOrLoad(ctx, "article:2", time.Minute, missing)
+	}
+	fmt.Println(errors.Is(err, ErrNotFound), atomic.LoadInt32(&calls))
+	// Output:
+	// magic-box <nil>
+	// 1
+	// magic-box <nil>
+	// true 2
+}
+
+func ExampleLock() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-008")
+	// 使用本地模拟的Redis服务演示
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	c, err := New(ctx, REDIS, Option{Host: server.Addr()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	locker := c.(Locker)
+	lock, err := locker.TryAcquire(ctx, "job:daily", LockOption{TTL: 10 * time.Second, Renew: true})
+	fmt.Println(err)
+	// 其他实例加锁失败，阻塞加锁在 ctx 结束时返回
+	_, err = locker.TryAcquire(ctx, "job:daily", LockOption{})
+	fmt.Println(err)
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = locker.Acquire(timeout, "job:daily", LockOption{})
+	fmt.Println(err)
+	fmt.Println(lock.Release(ctx))
+	fmt.Println(lock.Release(ctx))
+	// 有效期以毫秒写入Redis，不足1毫秒时拒绝
+	_, err = locker.TryAcquire(ctx, "job:hourly", LockOption{TTL: time.Microsecond})
+	fmt.Println(err)
+	lock, _ = locker.TryAcquire(ctx, "job:hourly", LockOption{})
+	fmt.Println(lock.Refresh(ctx, 0))
+	fmt.Println(lock.Release(ctx))
+	// Output:
+	// <nil>
+	// 锁已被占用
+	// context deadline exceeded
+	// <nil>
+	// 锁未持有或已过期
+	// 锁有效期不能小于1毫秒
+	// 锁有效期不能小于1毫秒
+	// <nil>
+}
+
+func ExampleInstrument() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-009")
+	c, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	metrics := NewMetrics("example_cache")
+	c = Chain(c, Instrument(InstrumentOption{Name: "session", Metrics: metrics}))
+	c.Set(ctx, "name", "magic-box")
+	c.Get(ctx, "name")
+	c.Get(ctx, "none")
+	var b strings.Builder
+	metrics.WriteTo(&b)
+	// 耗时不固定，仅输出次数
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, "example_cache_operations_total{") {
+			fmt.Println(line)
+		}
+	}
+	// Output:
+	// example_cache_operations_total{cache="session",operation="get",outcome="hit"} 1
+	// example_cache_operations_total{cache="session",operation="get",outcome="miss"} 1
+	// example_cache_operations_total{cache="session",operation="set",outcome="success"} 1
+}
+
+func ExamplePipeline() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-010")
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	c, err := New(ctx, REDIS, Option{Host: server.Addr()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 命令在 Exec 时一次发送
+	p := NewPipeline(c)
+	for i := 0; i < 3; i++ {
+		p.SetEx(fmt.Sprintf("warm:%d", i), i, 60)
+	}
+	p.Incr("warm:0")
+	p.Incr("name")
+	p.Set("name", "magic-box")
+	p.Incr("name")
+	results, err := p.Exec(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, result := range results {
+		fmt.Println(result.Reply, result.Err)
+	}
+	// Output:
+	// OK <nil>
+	// OK <nil>
+	// OK <nil>
+	// 1 <nil>
+	// 1 <nil>
+	// OK <nil>
+	// 0 ERR value is not an integer or out of range
+}
+
+func ExampleStructureCache() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-011")
+	c, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := c.(StructureCache)
+	// 哈希记录域名状态
+	s.HSet(ctx, "domain:example.com", map[string]interface{}{"status": "ok", "pages": 12})
+	pages, _ := redigo.Int(s.HGet(ctx, "domain:example.com", "pages"))
+	fmt.Println(pages)
+	// 有序集合按时间调度
+	s.ZAdd(ctx, "schedule", Z{Member: "b.com", Score: 20}, Z{Member: "a.com", Score: 10})
+	fmt.Println(s.ZPopMin(ctx, "schedule", 1))
+	// 列表作为工作队列
+	s.LPush(ctx, "queue", "job-1", "job-2")
+	job, _ := redigo.String(s.BRPop(ctx, "queue", time.Second))
+	fmt.Println(job)
+	fmt.Println(s.SAdd(ctx, "seen", "a.com", "a.com"))
+	fmt.Println(s.SIsMember(ctx, "seen", "a.com"))
+	// 类型不符时与Redis返回相同的错误
+	_, err = c.Get(ctx, "seen")
+	fmt.Println(err)
+	// Output:
+	// 12
+	// [{a.com 10}] <nil>
+	// job-1
+	// 1 <nil>
+	// true <nil>
+	// WRONGTYPE Operation against a key holding the wrong kind of value
+}
+
+func ExampleStructureCache_sortedSet() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-018")
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	// 成员与集合、列表的值使用相同的编码规则，Redis与内存缓存结果一致
+	for _, name := range []SupportType{REDIS, MEMORY} {
+		c, err := New(ctx, name, Option{Host: server.Addr()})
+		if err != nil {
+			log.Fatal(err)
+		}
+		s := c.(StructureCache)
+		s.ZAdd(ctx, "rank", Z{Member: `{"url":"a.com"}`, Score: 1.5}, Z{Member: "b.com", Score: 2})
+		s.SAdd(ctx, "seen", "b.com")
+		fmt.Println(s.ZRangeByScore(ctx, "rank", 0, math.Inf(1)))
+		members, _ := s.ZPopMin(ctx, "rank", 2)
+		fmt.Println(s.SIsMember(ctx, "seen", members[1].Member))
+		c.Close(ctx)
+	}
+	// Output:
+	// [{{"url":"a.com"} 1.5} {b.com 2}] <nil>
+	// true <nil>
+	// [{{"url":"a.com"} 1.5} {b.com 2}] <nil>
+	// true <nil>
+}
+
+func ExampleNamespace() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-012")
+	// 不同应用共用Redis时使用各自的键前缀
+	c, err := New(ctx, MEMORY, Option{Prefix: "crawler:prod:"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.Set(ctx, Key("domain", "a.com", "status"), "ok")
+	c.Set(ctx, Key("domain", "b.com", "status"), "ok")
+	c.Set(ctx, Key("job", 1), "running")
+	fmt.Println(Unwrap(c).Exists(ctx, "crawler:prod:job:1"))
+	// 按模式删除，模式同样添加前缀
+	fmt.Println(c.(PatternDeleter).DeleteByPattern(ctx, "domain:*"))
+	fmt.Println(c.Exists(ctx, "job:1"))
+	// Output:
+	// true <nil>
+	// 2 <nil>
+	// true <nil>
+}
+
+func ExampleSubscription() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-013")
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	c, err := New(ctx, REDIS, Option{Host: server.Addr()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := c.(*RedisCache)
+	ready := make(chan struct{}, 1)
+	// ctx 结束或调用 Close 后关闭消息通道，断开后自动重新订阅
+	sub, err := r.SubscribeWith(ctx, SubscribeOption{
+		Channels: []string{"config:flush"},
+		Patterns: []string{"crawler:*"},
+		OnSubscribe: func(ctx context.Context) {
+			ready <- struct{}{}
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sub.Close()
+	<-ready
+	r.Publish(ctx, "config:flush", "all")
+	r.Publish(ctx, "crawler:done", "a.com")
+	for i := 0; i < 2; i++ {
+		message := <-sub.Messages()
+		fmt.Println(message.Channel, message.Pattern, string(message.Data))
+	}
+	// Output:
+	// config:flush  all
+	// crawler:done crawler:* a.com
+}
+
+func ExampleOption_timeout() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-014")
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	// ctx 未设置截止时间时每次调用最多等待100毫秒
+	c, err := New(ctx, REDIS, Option{Host: server.Addr(), MaxActive: 1, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := c.(*RedisCache)
+	// 占用唯一的连接，后续调用等待空闲连接直到超时
+	conn := r.Pool.Get()
+	_, err = c.Get(ctx, "job:1")
+	fmt.Println(logger.CodeOf(err) == logger.TIMEOUT, errors.Is(err, context.DeadlineExceeded))
+	conn.Close()
+	// ctx 的截止时间优先于调用超时时间
+	deadline, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	fmt.Println(c.Set(deadline, "job:1", "running"))
+	// Output:
+	// true true
+	// OK <nil>
+}
+
+func ExampleRedisCache_Close() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-015")
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	c, err := New(ctx, REDIS, Option{Host: server.Addr()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := c.(*RedisCache)
+	sub, err := r.Subscribe(ctx, "config:flush")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 结束全部订阅后关闭连接池，最多等待5秒
+	closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	fmt.Println(c.Close(closeCtx))
+	_, ok := <-sub.Done()
+	fmt.Println(ok)
+	_, err = r.Subscribe(ctx, "config:flush")
+	fmt.Println(errors.Is(err, ErrClosed))
+	_, err = c.Get(ctx, "config:version")
+	fmt.Println(errors.Is(err, ErrClosed))
+	// 两级缓存关闭后批量读取同样返回 ErrClosed
+	tiered, err := New(ctx, TIERED, Option{Host: server.Addr()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(tiered.Close(closeCtx))
+	_, err = tiered.MGet(ctx, "config:version", "config:name")
+	fmt.Println(errors.Is(err, ErrClosed))
+	// Output:
+	// <nil>
+	// false
+	// true
+	// true
+	// <nil>
+	// true
+}
+
+func ExampleMemoryCache_Close() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-017")
+	c, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.Set(ctx, "name", "magic-box")
+	// 关闭时唤醒阻塞读取，此后的调用均返回 ErrClosed
+	done := make(chan error)
+	go func() {
+		_, err := c.(*MemoryCache).BRPop(ctx, "queue", 0)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	fmt.Println(c.Close(ctx))
+	fmt.Println(<-done)
+	_, err = c.Get(ctx, "name")
+	fmt.Println(err)
+	// 名称已存在时替换并关闭原对象
+	first, _ := New(ctx, MEMORY, Option{})
+	second, _ := New(ctx, MEMORY, Option{})
+	Register("memory-close", first)
+	Register("memory-close", second)
+	_, err = first.Set(ctx, "name", "magic-box")
+	fmt.Println(errors.Is(err, ErrClosed))
+	fmt.Println(Use("memory-close").Set(ctx, "name", "magic-box"))
+	// Output:
+	// <nil>
+	// 缓存对象已关闭
+	// 缓存对象已关闭
+	// true
+	// OK <nil>
+}
+
+func ExampleRedisCache_Expire() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-020")
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	c, err := New(ctx, REDIS, Option{Host: server.Addr()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close(ctx)
+	c.Set(ctx, "name", "magic-box")
+	// 有效期不大于0时返回错误，不会删除键
+	fmt.Println(c.Expire(ctx, "name", 0))
+	fmt.Println(c.Exists(ctx, "name"))
+	// 不足1毫秒时按1毫秒处理
+	fmt.Println(c.Expire(ctx, "name", time.Microsecond))
+	fmt.Println(c.TTL(ctx, "name"))
+	fmt.Println(c.SetNX(ctx, "lock", "a", 500*time.Microsecond))
+	fmt.Println(c.TTL(ctx, "lock"))
+	fmt.Println(c.SetNX(ctx, "lock", "a", -time.Second))
+	// Output:
+	// false 有效期必须大于0
+	// true <nil>
+	// true <nil>
+	// 1ms <nil>
+	// true <nil>
+	// 1ms <nil>
+	// false 有效期必须大于0
+}
+
+func ExampleLoader_panic() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-016")
+	c, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	loader, err := NewLoader(c, LoadOption{StaleTTL: time.Minute})
+	if err != nil {
+		log.Fatal(err)
+	}
+	broken := func(ctx context.Context) (interface{}, error) {
+		time.Sleep(10 * time.Millisecond)
+		panic("连接已断开")
+	}
+	// 加载函数发生 panic 时，并发等待的调用均得到错误
+	var wg sync.WaitGroup
+	var failed int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := loader.GetOrLoad(ctx, "article:1", time.Minute, broken); err != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println(atomic.LoadInt32(&failed))
+	// 后台刷新发生 panic 时不影响进程，仍返回旧值
+	c.Set(ctx, "article:2", wrapEnvelope([]byte("magic-box"), time.Now().Add(-time.Second), 0))
+	fmt.Println(redigo.String(loader.GetOrLoad(ctx, "article:2", time.Minute, broken)))
+	time.Sleep(50 * time.Millisecond)
+	_, err = loader.GetOrLoad(ctx, "article:3", time.Minute, broken)
+	fmt.Println(err)
+	// Output:
+	// 5
+	// magic-box <nil>
+	// 加载时发生panic: 连接已断开
+}
+
+func ExampleLoader_cancel() {
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-020")
+	c, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	loader, err := NewLoader(c, LoadOption{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		log.Fatal(err)
+	}
+	query := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return trace.TraceFrom(ctx), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	// 首个调用方取消后，加载继续进行，等待中的调用方仍得到结果
+	first, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, err := loader.GetOrLoad(first, "article:1", time.Minute, query)
+		done <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	value, err := redigo.String(loader.GetOrLoad(ctx, "article:1", time.Minute, query))
+	fmt.Println(value, err, <-done)
+	// 加载超过超时时间时返回错误
+	slow := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	_, err = loader.GetOrLoad(ctx, "article:2", time.Minute, slow)
+	fmt.Println(err)
+	// 替换默认对象后移除原对象的加载对象
+	Register(DEFAULT_INSTANCE, c)
+	GetOrLoad(ctx, "article:3", time.Minute, query)
+	_, ok := loaders.Load(c)
+	fmt.Println(ok)
+	other, err := New(ctx, MEMORY, Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	Register(DEFAULT_INSTANCE, other)
+	_, ok = loaders.Load(c)
+	fmt.Println(ok)
+	// Output:
+	// ctx-cache-020 <nil> <nil>
+	// context deadline exceeded
+	// true
+	// false
+}
+
+func ExampleLoader_GetOrLoadObject() {
+	type Article struct {
+		Title string
+		Views int
+	}
+	ctx := trace.WithTrace(context.Background(), "ctx-cache-021")
+	c, err := New(ctx, MEMORY, Option{Codec: MSGPACK})
+	if err != nil {
+		log.Fatal(err)
+	}
+	loader, err := NewLoader(c, LoadOption{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	query := func(ctx context.Context) (interface{}, error) {
+		return Article{Title: "magic-box", Views: 3}, nil
+	}
+	// 加载与读取缓存的结果均按序列化方式解码
+	for i := 0; i < 2; i++ {
+		var article Article
+		err = loader.GetOrLoadObject(ctx, "article:1", time.Minute, query, &article)
+		fmt.Println(article, err)
+	}
+	// Output:
+	// {magic-box 3} <nil>
+	// {magic-box 3} <nil>
 }
@@ -0,0 +1,441 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+)
+
+// 使用枚举限定操作结果
+type Outcome string
+
+const (
+	HIT     Outcome = "hit"     // 命中
+	MISS    Outcome = "miss"    // 未命中
+	SUCCESS Outcome = "success" // 非读取操作成功
+	FAILURE Outcome = "failure" // 操作失败
+	// 定义默认值
+	DEFAULT_SLOW_THRESHOLD = 100 * time.Millisecond
+	DEFAULT_METRICS_PREFIX = "magic_box_cache"
+)
+
+// 耗时分布的区间上限，单位为秒
+var durationBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// 一次缓存操作的记录
+type Event struct {
+	Name      string        `json:"name" label:"缓存名称"`
+	Operation string        `json:"operation" label:"操作"`
+	Keys      []string      `json:"keys" label:"键名"`
+	Duration  time.Duration `json:"duration" label:"耗时"`
+	Outcome   Outcome       `json:"outcome" label:"结果"`
+	Error     error         `json:"-" label:"错误"`
+}
+
+// 操作完成后调用，可用于记录指标或链路追踪
+type Hook func(ctx context.Context, event Event)
+
+// 包装缓存对象，不改变其行为
+type Middleware func(c Cache) Cache
+
+// 按顺序包装，第一个中间件位于最外层
+func Chain(c Cache, middlewares ...Middleware) Cache {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		c = middlewares[i](c)
+	}
+	return c
+}
+
+// 逐层获取被包装的缓存对象
+func Unwrap(c Cache) Cache {
+	for {
+		w, ok := c.(interface{ Unwrap() Cache })
+		if !ok {
+			return c
+		}
+		c = w.Unwrap()
+	}
+}
+
+// 监控时所用参数
+type InstrumentOption struct {
+	Name          string        `json:"name" label:"缓存名称" desc:"指标与日志中区分缓存对象，默认为default"`
+	SlowThreshold time.Duration `json:"slow_threshold" label:"慢操作阈值" desc:"默认100毫秒，为负数时不记录慢操作日志"`
+	Metrics       *Metrics      `json:"-" label:"指标对象" desc:"为空时使用默认指标对象"`
+	Hooks         []Hook        `json:"-" label:"其他回调"`
+}
+
+// 记录操作指标与慢操作日志的中间件
+func Instrument(option InstrumentOption) Middleware {
+	if option.SlowThreshold == 0 {
+		option.SlowThreshold = DEFAULT_SLOW_THRESHOLD
+	}
+	if option.Metrics == nil {
+		option.Metrics = DefaultMetrics
+	}
+	hooks := []Hook{option.Metrics.Observe}
+	if option.SlowThreshold > 0 {
+		hooks = append(hooks, SlowLog(option.SlowThreshold))
+	}
+	hooks = append(hooks, option.Hooks...)
+	return WithHooks(option.Name, hooks...)
+}
+
+// 每次操作完成后依次调用回调
+func WithHooks(name string, hooks ...Hook) Middleware {
+	if len(name) == 0 {
+		name = DEFAULT_INSTANCE
+	}
+	return func(c Cache) Cache {
+		return &hookedCache{next: c, name: name, hooks: hooks}
+	}
+}
+
+// 耗时达到阈值时记录日志，未初始化日志对象时不记录
+func SlowLog(threshold time.Duration) Hook {
+	return func(ctx context.Context, event Event) {
+		if event.Duration < threshold || logger.Default() == nil {
+			return
+		}
+		message := logger.Message{
+			Text:  fmt.Sprintf("缓存操作耗时过长: %s", event.Operation),
+			Label: trace.LabelFrom(ctx),
+			Attr: logger.Attr{
+				Monitor: logger.Monitor{
+					ProcessDuration: event.Duration.Milliseconds(),
+					Code:            logger.RWARN,
+					Level:           logger.WARN,
+				},
+				Inp: map[string]interface{}{
+					"cache":     event.Name,
+					"operation": event.Operation,
+					"keys":      event.Keys,
+				},
+				Oup: map[string]interface{}{
+					"outcome": event.Outcome,
+				},
+			},
+		}
+		if event.Error != nil {
+			message.Error = logger.WrapError(event.Error, logger.CALLERROR, "")
+		}
+		logger.Warn(ctx, message)
+	}
+}
+
+// 结构体
+// 包装缓存对象，操作完成后调用回调
+type hookedCache struct {
+	next  Cache
+	name  string
+	hooks []Hook
+}
+
+func (c *hookedCache) Unwrap() Cache {
+	return c.next
+}
+
+// 保持被包装对象的序列化方式
+func (c *hookedCache) Codec() Codec {
+	return CodecOf(c.next)
+}
+
+func (c *hookedCache) emit(ctx context.Context, operation string, keys []string, start time.Time, outcome Outcome, err error) {
+	if err != nil {
+		outcome = FAILURE
+	}
+	event := Event{
+		Name:      c.name,
+		Operation: operation,
+		Keys:      keys,
+		Duration:  time.Since(start),
+		Outcome:   outcome,
+		Error:     err,
+	}
+	for _, hook := range c.hooks {
+		hook(ctx, event)
+	}
+}
+
+func (c *hookedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	start := time.Now()
+	reply, err := c.next.Get(ctx, key)
+	outcome := HIT
+	if reply == nil {
+		outcome = MISS
+	}
+	c.emit(ctx, "get", []string{key}, start, outcome, err)
+	return reply, err
+}
+
+func (c *hookedCache) Set(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	start := time.Now()
+	reply, err := c.next.Set(ctx, key, value)
+	c.emit(ctx, "set", []string{key}, start, SUCCESS, err)
+	return reply, err
+}
+
+func (c *hookedCache) Overdue(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	overdue, err := c.next.Overdue(ctx, key)
+	c.emit(ctx, "overdue", []string{key}, start, SUCCESS, err)
+	return overdue, err
+}
+
+func (c *hookedCache) SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error) {
+	start := time.Now()
+	reply, err := c.next.SetEx(ctx, key, value, sec)
+	c.emit(ctx, "setex", []string{key}, start, SUCCESS, err)
+	return reply, err
+}
+
+func (c *hookedCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.next.SetNX(ctx, key, value, ttl)
+	c.emit(ctx, "setnx", []string{key}, start, SUCCESS, err)
+	return ok, err
+}
+
+func (c *hookedCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.next.Delete(ctx, keys...)
+	c.emit(ctx, "delete", keys, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) Exists(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	ok, err := c.next.Exists(ctx, key)
+	c.emit(ctx, "exists", []string{key}, start, SUCCESS, err)
+	return ok, err
+}
+
+func (c *hookedCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.next.Expire(ctx, key, ttl)
+	c.emit(ctx, "expire", []string{key}, start, SUCCESS, err)
+	return ok, err
+}
+
+func (c *hookedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := c.next.TTL(ctx, key)
+	c.emit(ctx, "ttl", []string{key}, start, SUCCESS, err)
+	return ttl, err
+}
+
+func (c *hookedCache) Incr(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	n, err := c.next.Incr(ctx, key)
+	c.emit(ctx, "incr", []string{key}, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	start := time.Now()
+	n, err := c.next.IncrBy(ctx, key, n)
+	c.emit(ctx, "incrby", []string{key}, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) Decr(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	n, err := c.next.Decr(ctx, key)
+	c.emit(ctx, "decr", []string{key}, start, SUCCESS, err)
+	return n, err
+}
+
+// 任一键命中即记为命中
+func (c *hookedCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	start := time.Now()
+	values, err := c.next.MGet(ctx, keys...)
+	outcome := MISS
+	for _, value := range values {
+		if value != nil {
+			outcome = HIT
+			break
+		}
+	}
+	c.emit(ctx, "mget", keys, start, outcome, err)
+	return values, err
+}
+
+func (c *hookedCache) MSet(ctx context.Context, values map[string]interface{}) error {
+	start := time.Now()
+	err := c.next.MSet(ctx, values)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	c.emit(ctx, "mset", keys, start, SUCCESS, err)
+	return err
+}
+
+// 关闭被包装对象，不记录事件
+func (c *hookedCache) Close(ctx context.Context) error {
+	return c.next.Close(ctx)
+}
+
+// 被包装对象支持分布式锁时可直接使用
+func (c *hookedCache) TryAcquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	locker, err := lockerOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	lock, err := locker.TryAcquire(ctx, key, option)
+	c.emit(ctx, "lock", []string{key}, start, SUCCESS, ignoreNotAcquired(err))
+	return lock, err
+}
+
+func (c *hookedCache) Acquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	locker, err := lockerOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	lock, err := locker.Acquire(ctx, key, option)
+	c.emit(ctx, "lock", []string{key}, start, SUCCESS, ignoreNotAcquired(err))
+	return lock, err
+}
+
+// 锁被占用属于正常结果，不计为失败
+func ignoreNotAcquired(err error) error {
+	if errors.Is(err, ErrLockNotAcquired) {
+		return nil
+	}
+	return err
+}
+
+// 结构体
+// 按缓存名称、操作与结果累计次数与耗时，输出Prometheus文本格式
+type Metrics struct {
+	prefix string
+	mu     sync.Mutex
+	series map[metricKey]*metricValue
+}
+
+type metricKey struct {
+	name      string
+	operation string
+	outcome   Outcome
+}
+
+type metricValue struct {
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+// 默认指标对象
+var DefaultMetrics = NewMetrics(DEFAULT_METRICS_PREFIX)
+
+// 创建指标对象，prefix 为指标名称前缀
+func NewMetrics(prefix string) *Metrics {
+	if len(prefix) == 0 {
+		prefix = DEFAULT_METRICS_PREFIX
+	}
+	return &Metrics{prefix: prefix, series: map[metricKey]*metricValue{}}
+}
+
+// 记录一次操作，可作为回调使用
+func (m *Metrics) Observe(ctx context.Context, event Event) {
+	seconds := event.Duration.Seconds()
+	key := metricKey{name: event.Name, operation: event.Operation, outcome: event.Outcome}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.series[key]
+	if !ok {
+		value = &metricValue{buckets: make([]uint64, len(durationBuckets))}
+		m.series[key] = value
+	}
+	value.count++
+	value.sum += seconds
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			value.buckets[i]++
+		}
+	}
+}
+
+// 按Prometheus文本格式输出
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	keys := make([]metricKey, 0, len(m.series))
+	values := make(map[metricKey]metricValue, len(m.series))
+	for key, value := range m.series {
+		keys = append(keys, key)
+		values[key] = metricValue{count: value.count, sum: value.sum, buckets: append([]uint64{}, value.buckets...)}
+	}
+	m.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+	var b strings.Builder
+	total := m.prefix + "_operations_total"
+	fmt.Fprintf(&b, "# HELP %s Total number of cache operations.\n# TYPE %s counter\n", total, total)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s{%s} %d\n", total, key.labels(true), values[key].count)
+	}
+	// 耗时分布不区分结果
+	histogram := m.prefix + "_operation_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s Latency of cache operations.\n# TYPE %s histogram\n", histogram, histogram)
+	for i := 0; i < len(keys); {
+		merged := metricValue{buckets: make([]uint64, len(durationBuckets))}
+		j := i
+		for ; j < len(keys) && keys[j].name == keys[i].name && keys[j].operation == keys[i].operation; j++ {
+			value := values[keys[j]]
+			merged.count += value.count
+			merged.sum += value.sum
+			for k := range value.buckets {
+				merged.buckets[k] += value.buckets[k]
+			}
+		}
+		labels := keys[i].labels(false)
+		for k, bound := range durationBuckets {
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%g\"} %d\n", histogram, labels, bound, merged.buckets[k])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", histogram, labels, merged.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %g\n", histogram, labels, merged.sum)
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", histogram, labels, merged.count)
+		i = j
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// 作为指标接口使用
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// 清空已记录的指标
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = map[metricKey]*metricValue{}
+}
+
+func (k metricKey) labels(outcome bool) string {
+	labels := fmt.Sprintf("cache=%q,operation=%q", k.name, k.operation)
+	if outcome {
+		labels += fmt.Sprintf(",outcome=%q", string(k.outcome))
+	}
+	return labels
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 加载函数返回该错误时视为数据不存在，可按 NegativeTTL 缓存
+var ErrNotFound = errors.New("数据不存在")
+
+// 读取失败时调用的加载函数
+type LoaderFunc func(ctx context.Context) (interface{}, error)
+
+// 加载时所用参数，均为0时不启用对应功能
+//
+// 未启用时写入原值；启用 StaleTTL 的值与 NegativeTTL 的不存在标记带有头部，
+// 这些键应只通过加载对象读取
+type LoadOption struct {
+	NegativeTTL time.Duration `json:"negative_ttl" label:"不存在结果的缓存时长" desc:"为0时不缓存"`
+	Jitter      float64       `json:"jitter" label:"有效期浮动比例" desc:"取值0到1，避免同时过期"`
+	StaleTTL    time.Duration `json:"stale_ttl" label:"过期后可用时长" desc:"期间返回旧值并在后台刷新"`
+	Timeout     time.Duration `json:"timeout" label:"加载超时时间" desc:"默认10秒，不受调用方取消的影响"`
+}
+
+// 结构体
+// 缓存旁路加载，同一个键的并发加载只执行一次
+type Loader struct {
+	Cache  Cache
+	Option LoadOption
+	group  group
+}
+
+// 过期后可用与不存在的结果带有头部，用于记录逻辑过期时间与不存在标记
+var envelopeMagic = []byte("\x00mbl\x01")
+
+const (
+	envelopeSize     = 14 // 标识5字节，标记1字节，逻辑过期时间8字节
+	envelopeNotFound = 1
+	// 定义默认值
+	DEFAULT_LOAD_TIMEOUT = 10 * time.Second
+)
+
+// 已注册的缓存对象对应的默认加载对象，替换注册时移除
+var loaders sync.Map
+
+// 创建加载对象
+func NewLoader(c Cache, option LoadOption) (*Loader, error) {
+	if c == nil {
+		return nil, errors.New("缓存对象不能为空")
+	}
+	if option.Jitter < 0 || option.Jitter > 1 || option.NegativeTTL < 0 || option.StaleTTL < 0 || option.Timeout < 0 {
+		return nil, errors.New("加载参数超出范围")
+	}
+	return &Loader{Cache: c, Option: option}, nil
+}
+
+// 读取缓存，不存在时调用 loader 加载并写入，返回值与 Get 一致
+//
+// ttl 为0时不过期；读取缓存失败时直接加载，写入失败不影响返回结果
+func (l *Loader) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	reply, err := l.Cache.Get(ctx, key)
+	if data, ok := reply.([]byte); ok && err == nil {
+		payload, expireAt, flag := unwrapEnvelope(data)
+		if !expireAt.IsZero() && time.Now().After(expireAt) {
+			if flag == envelopeNotFound {
+				return l.load(ctx, key, ttl, loader)
+			}
+			l.refresh(ctx, key, ttl, loader)
+		}
+		if flag == envelopeNotFound {
+			return nil, ErrNotFound
+		}
+		return payload, nil
+	}
+	return l.load(ctx, key, ttl, loader)
+}
+
+// 读取或加载并按缓存对象的序列化方式解码到 v
+func (l *Loader) GetOrLoadObject(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc, v interface{}) error {
+	reply, err := l.GetOrLoad(ctx, key, ttl, loader)
+	return decodeValue(CodecOf(l.Cache), reply, err, v)
+}
+
+// 并发调用共用一次加载，加载使用不随调用方取消的 ctx，避免首个调用方取消时其他调用方一同失败
+func (l *Loader) load(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	value, err := l.group.do(key, func() (interface{}, error) {
+		ctx, cancel := l.detach(ctx)
+		defer cancel()
+		return l.fill(ctx, key, ttl, loader)
+	})
+	// 并发调用共享同一个结果，复制后返回
+	if data, ok := value.([]byte); ok {
+		return cloneBytes(data), err
+	}
+	return value, err
+}
+
+// 后台刷新，已有加载进行时跳过
+func (l *Loader) refresh(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) {
+	l.group.doAsync(key, func() (interface{}, error) {
+		ctx, cancel := l.detach(ctx)
+		defer cancel()
+		return l.fill(ctx, key, ttl, loader)
+	})
+}
+
+// 保留 ctx 中的值，不随 ctx 取消，使用加载超时时间
+func (l *Loader) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := l.Option.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_LOAD_TIMEOUT
+	}
+	return context.WithTimeout(detachedContext{ctx}, timeout)
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func (l *Loader) fill(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	value, err := loader(ctx)
+	if errors.Is(err, ErrNotFound) {
+		if l.Option.NegativeTTL > 0 {
+			expireAt := time.Now().Add(l.Option.NegativeTTL)
+			l.store(ctx, key, wrapEnvelope(nil, expireAt, envelopeNotFound), l.Option.NegativeTTL)
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload, err := encodeValue(CodecOf(l.Cache), value)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		l.store(ctx, key, payload, 0)
+		return payload, nil
+	}
+	ttl = l.jitter(ttl)
+	if l.Option.StaleTTL <= 0 {
+		// 无需逻辑过期时间，写入原值，其他读取方式可直接使用
+		l.store(ctx, key, payload, ttl)
+		return payload, nil
+	}
+	l.store(ctx, key, wrapEnvelope(payload, time.Now().Add(ttl), 0), ttl+l.Option.StaleTTL)
+	return payload, nil
+}
+
+func (l *Loader) store(ctx context.Context, key string, data []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		l.Cache.Set(ctx, key, data)
+		return
+	}
+	// SETEX 只支持秒，向上取整
+	l.Cache.SetEx(ctx, key, data, int((ttl+time.Second-1)/time.Second))
+}
+
+// 在 ttl 上下随机浮动，避免同时写入的键同时过期
+func (l *Loader) jitter(ttl time.Duration) time.Duration {
+	if l.Option.Jitter == 0 {
+		return ttl
+	}
+	delta := (rand.Float64()*2 - 1) * l.Option.Jitter * float64(ttl)
+	if ttl += time.Duration(delta); ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return ttl
+}
+
+func wrapEnvelope(payload []byte, expireAt time.Time, flag byte) []byte {
+	data := make([]byte, envelopeSize, envelopeSize+len(payload))
+	copy(data, envelopeMagic)
+	data[len(envelopeMagic)] = flag
+	if !expireAt.IsZero() {
+		binary.BigEndian.PutUint64(data[len(envelopeMagic)+1:], uint64(expireAt.UnixNano()/int64(time.Millisecond)))
+	}
+	return append(data, payload...)
+}
+
+// 拆分头部，不带头部的数据视为未过期的值
+func unwrapEnvelope(data []byte) ([]byte, time.Time, byte) {
+	if len(data) < envelopeSize || !bytes.HasPrefix(data, envelopeMagic) {
+		return data, time.Time{}, 0
+	}
+	var expireAt time.Time
+	if ms := binary.BigEndian.Uint64(data[len(envelopeMagic)+1:]); ms > 0 {
+		expireAt = time.Unix(0, int64(ms)*int64(time.Millisecond))
+	}
+	return data[envelopeSize:], expireAt, data[len(envelopeMagic)]
+}
+
+// 使用默认对象读取或加载，不启用不存在缓存、有效期浮动与过期后可用
+func GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	c := Default()
+	if c == nil {
+		return loader(ctx)
+	}
+	l, _ := loaders.LoadOrStore(c, &Loader{Cache: c})
+	if Default() != c {
+		// 期间默认对象已被替换，移除对应的加载对象
+		loaders.Delete(c)
+	}
+	return l.(*Loader).GetOrLoad(ctx, key, ttl, loader)
+}
+
+// 合并同一个键的并发调用
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+func (g *group) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	c, started := g.start(key)
+	if !started {
+		c.wg.Wait()
+		return c.value, c.err
+	}
+	g.run(key, c, fn)
+	return c.value, c.err
+}
+
+func (g *group) doAsync(key string, fn func() (interface{}, error)) {
+	if c, started := g.start(key); started {
+		go g.run(key, c, fn)
+	}
+}
+
+func (g *group) start(key string) (*call, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	if c, ok := g.calls[key]; ok {
+		return c, false
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	return c, true
+}
+
+// 加载函数发生 panic 时转换为错误，等待中的调用得到同一个错误
+func (g *group) run(key string, c *call, fn func() (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.value, c.err = nil, fmt.Errorf("加载时发生panic: %v", r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.value, c.err = fn()
+}
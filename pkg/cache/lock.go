@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_LOCK_TTL       = 30 * time.Second
+	DEFAULT_LOCK_RETRY_MIN = 50 * time.Millisecond
+	DEFAULT_LOCK_RETRY_MAX = time.Second
+)
+
+var (
+	ErrLockNotAcquired = errors.New("锁已被占用")
+	ErrLockNotHeld     = errors.New("锁未持有或已过期")
+	errLockTTL         = errors.New("锁有效期不能小于1毫秒")
+)
+
+// 持有者一致时删除
+var releaseScript = redigo.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// 持有者一致时续期
+var extendScript = redigo.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// 加锁时所用参数
+type LockOption struct {
+	TTL      time.Duration `json:"ttl" label:"锁有效期" desc:"默认30秒"`
+	Renew    bool          `json:"renew" label:"是否自动续期" desc:"持有期间每隔三分之一有效期续期"`
+	RetryMin time.Duration `json:"retry_min" label:"最小重试间隔" desc:"阻塞加锁使用，默认50毫秒"`
+	RetryMax time.Duration `json:"retry_max" label:"最大重试间隔" desc:"阻塞加锁使用，默认1秒"`
+}
+
+// 支持分布式锁的缓存对象
+type Locker interface {
+	TryAcquire(ctx context.Context, key string, option LockOption) (*Lock, error)
+	Acquire(ctx context.Context, key string, option LockOption) (*Lock, error)
+}
+
+// 结构体
+// 基于Redis的分布式锁，通过随机令牌识别持有者
+type Lock struct {
+	cache  *RedisCache
+	key    string
+	token  string
+	ttl    time.Duration
+	lost   chan struct{}
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// 有效期以毫秒为单位写入Redis，不足1毫秒时返回错误
+func applyLockOption(option *LockOption) error {
+	if option.TTL < 0 || (option.TTL > 0 && option.TTL < time.Millisecond) {
+		return errLockTTL
+	}
+	if option.TTL == 0 {
+		option.TTL = DEFAULT_LOCK_TTL
+	}
+	if option.RetryMin <= 0 {
+		option.RetryMin = DEFAULT_LOCK_RETRY_MIN
+	}
+	if option.RetryMax < option.RetryMin {
+		option.RetryMax = DEFAULT_LOCK_RETRY_MAX
+		if option.RetryMax < option.RetryMin {
+			option.RetryMax = option.RetryMin
+		}
+	}
+	return nil
+}
+
+// 尝试加锁一次，已被占用时返回 ErrLockNotAcquired
+func (c *RedisCache) TryAcquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	if err := applyLockOption(&option); err != nil {
+		return nil, err
+	}
+	token := trace.NewTrace()
+	reply, err := c.do(ctx, "SET", key, token, "NX", "PX", option.TTL.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrLockNotAcquired
+	}
+	l := &Lock{
+		cache:  c,
+		key:    key,
+		token:  token,
+		ttl:    option.TTL,
+		lost:   make(chan struct{}),
+		cancel: func() {},
+	}
+	if option.Renew {
+		renewCtx, cancel := context.WithCancel(context.Background())
+		l.cancel = cancel
+		go l.renew(renewCtx)
+	}
+	return l, nil
+}
+
+// 阻塞加锁，按指数退避重试直到成功或 ctx 结束
+func (c *RedisCache) Acquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	if err := applyLockOption(&option); err != nil {
+		return nil, err
+	}
+	interval := option.RetryMin
+	for {
+		l, err := c.TryAcquire(ctx, key, option)
+		if !errors.Is(err, ErrLockNotAcquired) {
+			return l, err
+		}
+		// 随机等待一半到全部间隔，避免多个实例同时重试
+		wait := interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if interval *= 2; interval > option.RetryMax {
+			interval = option.RetryMax
+		}
+	}
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// 自动续期失败或锁已被他人持有时关闭
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// 延长有效期，锁已不属于当前持有者时返回 ErrLockNotHeld
+//
+// 有效期不足1毫秒时返回错误，避免写入0时释放锁
+func (l *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return errLockTTL
+	}
+	ok, err := redigo.Bool(l.cache.eval(ctx, extendScript, l.key, l.token, ttl.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// 释放锁，锁已不属于当前持有者时返回 ErrLockNotHeld
+func (l *Lock) Release(ctx context.Context) error {
+	l.stop()
+	ok, err := redigo.Bool(l.cache.eval(ctx, releaseScript, l.key, l.token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// 执行脚本，超时处理与 do 一致
+func (c *RedisCache) eval(ctx context.Context, script *redigo.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	r, err := c.conn(ctx, c.Pool)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var reply interface{}
+	if ctx.Done() == nil {
+		reply, err = script.Do(r, keysAndArgs...)
+	} else {
+		reply, err = script.DoContext(ctx, r, keysAndArgs...)
+	}
+	if err != nil {
+		return nil, logger.WrapTimeout(err, logger.CALLTIMEOUT)
+	}
+	return reply, nil
+}
+
+func (l *Lock) stop() {
+	l.once.Do(l.cancel)
+}
+
+// 每隔三分之一有效期续期，超过有效期仍未成功时视为丢失
+func (l *Lock) renew(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 每次续期最多等待一个续期间隔，Redis无响应时仍能按时判定丢失
+		refreshCtx, cancel := context.WithTimeout(ctx, l.ttl/3)
+		err := l.Refresh(refreshCtx, l.ttl)
+		cancel()
+		if err == nil {
+			renewed = time.Now()
+			continue
+		}
+		if errors.Is(err, ErrLockNotHeld) || time.Since(renewed) >= l.ttl {
+			close(l.lost)
+			return
+		}
+	}
+}
+
+// 两级缓存的锁直接使用Redis
+func (c *TieredCache) TryAcquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	return c.Remote.TryAcquire(ctx, key, option)
+}
+
+func (c *TieredCache) Acquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	return c.Remote.Acquire(ctx, key, option)
+}
+
+func lockerOf(c Cache) (Locker, error) {
+	locker, ok := c.(Locker)
+	if !ok {
+		return nil, errors.New("缓存对象不支持分布式锁")
+	}
+	return locker, nil
+}
+
+func TryAcquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	locker, err := lockerOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return locker.TryAcquire(ctx, key, option)
+}
+
+func Acquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	locker, err := lockerOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return locker.Acquire(ctx, key, option)
+}
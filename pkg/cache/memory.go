@@ -0,0 +1,462 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_MAX_ENTRIES = 10000
+	// 与Redis保持一致的返回值
+	REPLY_OK = "OK"
+)
+
+// 内存缓存的运行统计
+type Stats struct {
+	Hits        uint64 `json:"hits" label:"命中次数"`
+	Misses      uint64 `json:"misses" label:"未命中次数"`
+	Evictions   uint64 `json:"evictions" label:"淘汰次数"`
+	Expirations uint64 `json:"expirations" label:"过期次数"`
+	Entries     int    `json:"entries" label:"键数量"`
+	Bytes       int64  `json:"bytes" label:"占用字节数"`
+}
+
+// 结构体
+// 基于进程内存，按LRU淘汰
+type MemoryCache struct {
+	maxEntries  int
+	maxBytes    int64
+	bytes       int64
+	items       map[string]*list.Element
+	order       *list.List
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+	codec       Codec
+	pushed      chan struct{} // 写入列表时关闭并替换，用于唤醒阻塞读取
+	closed      bool
+	mu          sync.Mutex
+}
+
+type memoryEntry struct {
+	key      string
+	value    []byte
+	data     interface{} // 哈希、列表、集合与有序集合，字符串时为nil
+	extra    int64       // data 占用的字节数
+	expireAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key)+len(e.value)) + e.extra
+}
+
+// 创建基于内存的对象
+func NewMemoryCache(ctx context.Context, option Option) (Cache, error) {
+	if option.MaxEntries < 0 || option.MaxBytes < 0 {
+		return nil, errors.New("最大键数量与最大占用字节数不能为负数")
+	}
+	if option.MaxEntries == 0 {
+		option.MaxEntries = DEFAULT_MAX_ENTRIES
+	}
+	codec, err := CodecFactory(option.Codec)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryCache{
+		codec:      codec,
+		maxEntries: option.MaxEntries,
+		maxBytes:   option.MaxBytes,
+		items:      map[string]*list.Element{},
+		order:      list.New(),
+	}, nil
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	entry, ok := c.lookup(key)
+	if !ok {
+		c.misses++
+		return nil, nil
+	}
+	if entry.data != nil {
+		return nil, errWrongType
+	}
+	c.hits++
+	return cloneBytes(entry.value), nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	data, err := encodeValue(c.codec, value)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	c.store(key, data, time.Time{})
+	return REPLY_OK, nil
+}
+
+// 剩余有效期超过1秒时为 true，与Redis一致
+func (c *MemoryCache) Overdue(ctx context.Context, key string) (bool, error) {
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+	entry, ok := c.lookup(key)
+	return ok && !entry.expireAt.IsZero() && time.Until(entry.expireAt) > time.Second, nil
+}
+
+func (c *MemoryCache) SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error) {
+	if sec <= 0 {
+		return nil, redigo.Error("ERR invalid expire time in 'setex' command")
+	}
+	data, err := encodeValue(c.codec, value)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	c.store(key, data, time.Now().Add(time.Duration(sec)*time.Second))
+	return REPLY_OK, nil
+}
+
+func (c *MemoryCache) Codec() Codec {
+	return c.codec
+}
+
+// 删除键，返回实际删除的数量
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	var count int64
+	for _, key := range keys {
+		if _, ok := c.lookup(key); ok {
+			c.remove(c.items[key])
+			count++
+		}
+	}
+	return count, nil
+}
+
+// 键不存在时写入，ttl 为0时不过期，小于0时返回 ErrInvalidTTL
+func (c *MemoryCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	if ttl < 0 {
+		return false, ErrInvalidTTL
+	}
+	data, err := encodeValue(c.codec, value)
+	if err != nil {
+		return false, err
+	}
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+	if _, ok := c.lookup(key); ok {
+		return false, nil
+	}
+	c.store(key, data, expireAt(ttl))
+	return true, nil
+}
+
+func (c *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+	_, ok := c.lookup(key)
+	return ok, nil
+}
+
+// 设置有效期，键不存在时返回 false，ttl 不大于0时返回 ErrInvalidTTL
+func (c *MemoryCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, ErrInvalidTTL
+	}
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+	entry, ok := c.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	entry.expireAt = time.Now().Add(roundTTL(ttl))
+	return true, nil
+}
+
+// 剩余有效期，精确到毫秒，键不存在时为 TTL_MISSING，未设置有效期时为 TTL_PERSISTENT
+func (c *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	entry, ok := c.lookup(key)
+	if !ok {
+		return TTL_MISSING, nil
+	}
+	if entry.expireAt.IsZero() {
+		return TTL_PERSISTENT, nil
+	}
+	return time.Until(entry.expireAt).Truncate(time.Millisecond), nil
+}
+
+func (c *MemoryCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.IncrBy(ctx, key, 1)
+}
+
+func (c *MemoryCache) Decr(ctx context.Context, key string) (int64, error) {
+	return c.IncrBy(ctx, key, -1)
+}
+
+// 按整数累加，保留原有效期
+func (c *MemoryCache) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	var current int64
+	var deadline time.Time
+	if entry, ok := c.lookup(key); ok {
+		if entry.data != nil {
+			return 0, errWrongType
+		}
+		value, err := strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, redigo.Error("ERR value is not an integer or out of range")
+		}
+		current, deadline = value, entry.expireAt
+	}
+	if (n > 0 && current > math.MaxInt64-n) || (n < 0 && current < math.MinInt64-n) {
+		return 0, redigo.Error("ERR increment or decrement would overflow")
+	}
+	current += n
+	c.store(key, strconv.AppendInt(nil, current, 10), deadline)
+	return current, nil
+}
+
+// 批量读取，不存在的键对应 nil
+func (c *MemoryCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		// 非字符串的键对应 nil
+		if entry, ok := c.lookup(key); ok && entry.data == nil {
+			c.hits++
+			values[i] = cloneBytes(entry.value)
+		} else {
+			c.misses++
+		}
+	}
+	return values, nil
+}
+
+// 批量写入
+func (c *MemoryCache) MSet(ctx context.Context, values map[string]interface{}) error {
+	data := make(map[string][]byte, len(values))
+	for key, value := range values {
+		encoded, err := encodeValue(c.codec, value)
+		if err != nil {
+			return err
+		}
+		data[key] = encoded
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	for key, value := range data {
+		c.store(key, value, time.Time{})
+	}
+	return nil
+}
+
+// 清空全部键并唤醒阻塞读取，关闭后的调用均返回 ErrClosed，重复调用时直接返回
+func (c *MemoryCache) Close(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.flush()
+	if c.pushed != nil {
+		close(c.pushed)
+		c.pushed = nil
+	}
+	return nil
+}
+
+// 加锁，已关闭时返回 ErrClosed
+func (c *MemoryCache) lock() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	return nil
+}
+
+// 清空全部键
+func (c *MemoryCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flush()
+}
+
+func (c *MemoryCache) flush() {
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+	c.bytes = 0
+}
+
+// 运行统计
+func (c *MemoryCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:        c.hits,
+		Misses:      c.misses,
+		Evictions:   c.evictions,
+		Expirations: c.expirations,
+		Entries:     len(c.items),
+		Bytes:       c.bytes,
+	}
+}
+
+// 清理全部已过期的键
+func (c *MemoryCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for _, element := range c.items {
+		if element.Value.(*memoryEntry).expired(now) {
+			c.remove(element)
+			c.expirations++
+			count++
+		}
+	}
+	return count
+}
+
+func (c *MemoryCache) setWithTTL(key string, value []byte, ttl time.Duration) {
+	if c.lock() != nil {
+		return
+	}
+	defer c.mu.Unlock()
+	c.store(key, value, time.Now().Add(ttl))
+}
+
+// ttl 为0时不过期
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(roundTTL(ttl))
+}
+
+// 查找未过期的键，已过期的键会被移除
+func (c *MemoryCache) lookup(key string) (*memoryEntry, bool) {
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		c.remove(element)
+		c.expirations++
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry, true
+}
+
+func (c *MemoryCache) store(key string, value []byte, expireAt time.Time) {
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+	entry := &memoryEntry{key: key, value: value, expireAt: expireAt}
+	c.items[key] = c.order.PushFront(entry)
+	c.bytes += entry.size()
+	c.evict()
+}
+
+func (c *MemoryCache) remove(element *list.Element) {
+	entry := element.Value.(*memoryEntry)
+	c.order.Remove(element)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size()
+}
+
+// 超出限制时淘汰最久未使用的键，至少保留最新写入的键
+func (c *MemoryCache) evict() {
+	for c.order.Len() > 1 {
+		if len(c.items) <= c.maxEntries && (c.maxBytes == 0 || c.bytes <= c.maxBytes) {
+			return
+		}
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// 按Redis的规则将参数转换为字节
+func encodeArg(value interface{}) []byte {
+	switch value := value.(type) {
+	case string:
+		return []byte(value)
+	case []byte:
+		return cloneBytes(value)
+	case int:
+		return strconv.AppendInt(nil, int64(value), 10)
+	case int64:
+		return strconv.AppendInt(nil, value, 10)
+	case float64:
+		return strconv.AppendFloat(nil, value, 'g', -1, 64)
+	case bool:
+		if value {
+			return []byte("1")
+		}
+		return []byte("0")
+	case nil:
+		return []byte{}
+	case redigo.Argument:
+		return encodeArg(value.RedisArg())
+	default:
+		var buffer bytes.Buffer
+		fmt.Fprint(&buffer, value)
+		return buffer.Bytes()
+	}
+}
+
+func cloneBytes(value []byte) []byte {
+	result := make([]byte, len(value))
+	copy(result, value)
+	return result
+}
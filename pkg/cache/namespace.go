@@ -0,0 +1,474 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/redisconn"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_SEPARATOR  = ":"
+	DEFAULT_SCAN_COUNT = 100
+)
+
+// 按模式删除键
+type PatternDeleter interface {
+	DeleteByPattern(ctx context.Context, pattern string) (int64, error)
+}
+
+// 使用分隔符拼接键名，例如 Key("user", 1, "profile") 为 user:1:profile
+func Key(parts ...interface{}) string {
+	values := make([]string, len(parts))
+	for i, part := range parts {
+		values[i] = fmt.Sprint(part)
+	}
+	return strings.Join(values, DEFAULT_SEPARATOR)
+}
+
+// 为全部键添加前缀的中间件，读取与写入时对调用方透明
+func Namespace(prefix string) Middleware {
+	return func(c Cache) Cache {
+		if len(prefix) == 0 {
+			return c
+		}
+		return &namespacedCache{next: c, prefix: prefix}
+	}
+}
+
+// 结构体
+// 为全部键添加前缀
+type namespacedCache struct {
+	next   Cache
+	prefix string
+}
+
+func (c *namespacedCache) key(key string) string {
+	return c.prefix + key
+}
+
+func (c *namespacedCache) keys(keys []string) []string {
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = c.prefix + key
+	}
+	return result
+}
+
+func (c *namespacedCache) Unwrap() Cache {
+	return c.next
+}
+
+func (c *namespacedCache) Codec() Codec {
+	return CodecOf(c.next)
+}
+
+// 键前缀
+func (c *namespacedCache) Prefix() string {
+	return c.prefix
+}
+
+func (c *namespacedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	return c.next.Get(ctx, c.key(key))
+}
+
+func (c *namespacedCache) Set(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	return c.next.Set(ctx, c.key(key), value)
+}
+
+func (c *namespacedCache) Overdue(ctx context.Context, key string) (bool, error) {
+	return c.next.Overdue(ctx, c.key(key))
+}
+
+func (c *namespacedCache) SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error) {
+	return c.next.SetEx(ctx, c.key(key), value, sec)
+}
+
+func (c *namespacedCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	return c.next.SetNX(ctx, c.key(key), value, ttl)
+}
+
+func (c *namespacedCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	return c.next.Delete(ctx, c.keys(keys)...)
+}
+
+func (c *namespacedCache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.next.Exists(ctx, c.key(key))
+}
+
+func (c *namespacedCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return c.next.Expire(ctx, c.key(key), ttl)
+}
+
+func (c *namespacedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.next.TTL(ctx, c.key(key))
+}
+
+func (c *namespacedCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.next.Incr(ctx, c.key(key))
+}
+
+func (c *namespacedCache) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return c.next.IncrBy(ctx, c.key(key), n)
+}
+
+func (c *namespacedCache) Decr(ctx context.Context, key string) (int64, error) {
+	return c.next.Decr(ctx, c.key(key))
+}
+
+func (c *namespacedCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	return c.next.MGet(ctx, c.keys(keys)...)
+}
+
+func (c *namespacedCache) MSet(ctx context.Context, values map[string]interface{}) error {
+	prefixed := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		prefixed[c.key(key)] = value
+	}
+	return c.next.MSet(ctx, prefixed)
+}
+
+func (c *namespacedCache) Close(ctx context.Context) error {
+	return c.next.Close(ctx)
+}
+
+// 模式同样添加前缀，前缀中的通配符按普通字符处理
+func (c *namespacedCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	d, err := patternDeleterOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	return d.DeleteByPattern(ctx, escapePattern(c.prefix)+pattern)
+}
+
+// 锁的键名包含前缀
+func (c *namespacedCache) TryAcquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	locker, err := lockerOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return locker.TryAcquire(ctx, c.key(key), option)
+}
+
+func (c *namespacedCache) Acquire(ctx context.Context, key string, option LockOption) (*Lock, error) {
+	locker, err := lockerOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return locker.Acquire(ctx, c.key(key), option)
+}
+
+// 命令参数以键名开头，执行前为键名添加前缀
+func (c *namespacedCache) execPipeline(ctx context.Context, ops []pipelineOp) ([]PipelineResult, error) {
+	prefixed := make([]pipelineOp, len(ops))
+	for i, op := range ops {
+		call := op.call
+		op.args = append(redigo.Args{}.AddFlat(c.keys(op.keys)), op.args[len(op.keys):]...)
+		op.keys = c.keys(op.keys)
+		op.call = func(ctx context.Context, next Cache) (interface{}, error) {
+			return call(ctx, &namespacedCache{next: next, prefix: c.prefix})
+		}
+		prefixed[i] = op
+	}
+	return execPipeline(ctx, c.next, prefixed)
+}
+
+func (c *namespacedCache) HGet(ctx context.Context, key string, field string) (interface{}, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return s.HGet(ctx, c.key(key), field)
+}
+
+func (c *namespacedCache) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	return s.HSet(ctx, c.key(key), values)
+}
+
+func (c *namespacedCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return s.HGetAll(ctx, c.key(key))
+}
+
+func (c *namespacedCache) LPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	return s.LPush(ctx, c.key(key), values...)
+}
+
+func (c *namespacedCache) RPop(ctx context.Context, key string) (interface{}, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return s.RPop(ctx, c.key(key))
+}
+
+func (c *namespacedCache) BRPop(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return s.BRPop(ctx, c.key(key), timeout)
+}
+
+func (c *namespacedCache) SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	return s.SAdd(ctx, c.key(key), members...)
+}
+
+func (c *namespacedCache) SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return false, err
+	}
+	return s.SIsMember(ctx, c.key(key), member)
+}
+
+func (c *namespacedCache) ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	return s.ZAdd(ctx, c.key(key), members...)
+}
+
+func (c *namespacedCache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]Z, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return s.ZRangeByScore(ctx, c.key(key), min, max)
+}
+
+func (c *namespacedCache) ZPopMin(ctx context.Context, key string, count int64) ([]Z, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return s.ZPopMin(ctx, c.key(key), count)
+}
+
+func patternDeleterOf(c Cache) (PatternDeleter, error) {
+	d, ok := c.(PatternDeleter)
+	if !ok {
+		return nil, errors.New("缓存对象不支持按模式删除")
+	}
+	return d, nil
+}
+
+// 使用SCAN逐批查找并删除，不使用KEYS，集群模式下遍历全部节点
+//
+// 删除期间写入的键可能不会被删除
+func (c *RedisCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	nodes, err := redisconn.Nodes(c.Pool)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, node := range nodes {
+		n, err := c.deleteByPattern(ctx, node.Pool, pattern)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// 调用超时时间作用于每次调用，而非整个删除过程
+func (c *RedisCache) deleteByPattern(ctx context.Context, pool *redigo.Pool, pattern string) (int64, error) {
+	getCtx, cancel := c.withTimeout(ctx)
+	r, err := c.conn(getCtx, pool)
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	var total int64
+	cursor := "0"
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		values, err := redigo.Values(c.doConn(ctx, r, "SCAN", cursor, "MATCH", pattern, "COUNT", DEFAULT_SCAN_COUNT))
+		if err != nil {
+			return total, err
+		}
+		var keys []string
+		if _, err := redigo.Scan(values, &cursor, &keys); err != nil {
+			return total, err
+		}
+		if len(keys) > 0 {
+			// 逐个删除，避免集群中跨槽位
+			for _, key := range keys {
+				if err := r.Send("UNLINK", key); err != nil {
+					return total, err
+				}
+			}
+			counts, err := redigo.Int64s(c.doConn(ctx, r, ""))
+			if err != nil {
+				return total, err
+			}
+			for _, n := range counts {
+				total += n
+			}
+		}
+		if cursor == "0" {
+			return total, nil
+		}
+	}
+}
+
+// 模式规则与Redis一致
+func (c *MemoryCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	now := time.Now()
+	var count int64
+	for key, element := range c.items {
+		if !matchPattern(pattern, key) {
+			continue
+		}
+		expired := element.Value.(*memoryEntry).expired(now)
+		c.remove(element)
+		if expired {
+			c.expirations++
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// 删除远端与本地的键，并通知其他实例
+func (c *TieredCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	n, err := c.Remote.DeleteByPattern(ctx, pattern)
+	c.Local.DeleteByPattern(ctx, pattern)
+	c.send(ctx, invalidation{ID: c.id, Pattern: pattern})
+	return n, err
+}
+
+func (c *hookedCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	d, err := patternDeleterOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	n, err := d.DeleteByPattern(ctx, pattern)
+	c.emit(ctx, "deletebypattern", []string{pattern}, start, SUCCESS, err)
+	return n, err
+}
+
+// 按Redis的规则匹配，支持 * ? [abc] [^a] [a-z] 与 \ 转义
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				switch {
+				case pattern[0] == '\\' && len(pattern) > 1:
+					pattern = pattern[1:]
+					match = match || pattern[0] == s[0]
+				case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+					lo, hi := pattern[0], pattern[2]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					match = match || (s[0] >= lo && s[0] <= hi)
+					pattern = pattern[2:]
+				default:
+					match = match || pattern[0] == s[0]
+				}
+				pattern = pattern[1:]
+			}
+			if match == not {
+				return false
+			}
+			// 缺少 ] 时视为模式结束
+			if len(pattern) == 0 {
+				return len(s) == 1
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		s = s[1:]
+		pattern = pattern[1:]
+	}
+	return len(s) == 0
+}
+
+// 转义模式中的特殊字符
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	d, err := patternDeleterOf(Default())
+	if err != nil {
+		return 0, err
+	}
+	return d.DeleteByPattern(ctx, pattern)
+}
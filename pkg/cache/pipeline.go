@@ -0,0 +1,324 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// 批量操作中单条命令的结果
+type PipelineResult struct {
+	Reply interface{} `json:"reply" label:"返回值" desc:"与对应方法的返回值类型一致"`
+	Err   error       `json:"-" label:"错误"`
+}
+
+// 支持在一次往返中执行多条命令的缓存对象
+type pipeliner interface {
+	execPipeline(ctx context.Context, ops []pipelineOp) ([]PipelineResult, error)
+}
+
+// 排队中的命令，Redis使用 cmd 与 args，其他缓存对象调用 call
+type pipelineOp struct {
+	write bool
+	keys  []string
+	cmd   string
+	args  []interface{}
+	err   error // 入队时发生的错误，例如编码失败
+	parse func(reply interface{}, err error) (interface{}, error)
+	call  func(ctx context.Context, c Cache) (interface{}, error)
+}
+
+// 结构体
+// 批量操作，命令在 Exec 时按入队顺序执行
+//
+// 基于Redis时一次发送全部命令；其他缓存对象依次执行，结果一致
+type Pipeline struct {
+	cache Cache
+	codec Codec
+	ops   []pipelineOp
+}
+
+// 创建批量操作对象，不可并发使用
+func NewPipeline(c Cache) *Pipeline {
+	return &Pipeline{cache: c, codec: CodecOf(c)}
+}
+
+// 已入队的命令数量
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+func (p *Pipeline) Get(key string) {
+	p.ops = append(p.ops, pipelineOp{
+		keys:  []string{key},
+		cmd:   "GET",
+		args:  []interface{}{key},
+		parse: parseReply,
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.Get(ctx, key)
+		},
+	})
+}
+
+func (p *Pipeline) Set(key string, value interface{}) {
+	data, err := encodeValue(p.codec, value)
+	p.ops = append(p.ops, pipelineOp{
+		write: true,
+		keys:  []string{key},
+		cmd:   "SET",
+		args:  []interface{}{key, data},
+		err:   err,
+		parse: parseReply,
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.Set(ctx, key, value)
+		},
+	})
+}
+
+func (p *Pipeline) SetEx(key string, value interface{}, sec int) {
+	data, err := encodeValue(p.codec, value)
+	p.ops = append(p.ops, pipelineOp{
+		write: true,
+		keys:  []string{key},
+		cmd:   "SETEX",
+		args:  []interface{}{key, sec, data},
+		err:   err,
+		parse: parseReply,
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.SetEx(ctx, key, value, sec)
+		},
+	})
+}
+
+// 返回值为 bool
+func (p *Pipeline) SetNX(key string, value interface{}, ttl time.Duration) {
+	data, err := encodeValue(p.codec, value)
+	if ttl < 0 {
+		err = ErrInvalidTTL
+	}
+	args := redigo.Args{key, data, "NX"}
+	if ttl > 0 {
+		args = args.Add("PX", roundTTL(ttl).Milliseconds())
+	}
+	p.ops = append(p.ops, pipelineOp{
+		write: true,
+		keys:  []string{key},
+		cmd:   "SET",
+		args:  args,
+		err:   err,
+		parse: func(reply interface{}, err error) (interface{}, error) {
+			if err != nil {
+				return false, err
+			}
+			return reply != nil, nil
+		},
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.SetNX(ctx, key, value, ttl)
+		},
+	})
+}
+
+// 返回值为 int64
+func (p *Pipeline) Delete(keys ...string) {
+	op := pipelineOp{
+		write: true,
+		keys:  keys,
+		cmd:   "DEL",
+		args:  redigo.Args{}.AddFlat(keys),
+		parse: parseInt64,
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.Delete(ctx, keys...)
+		},
+	}
+	if len(keys) == 0 {
+		// 不发送命令
+		op.cmd = ""
+		op.parse = func(reply interface{}, err error) (interface{}, error) {
+			return int64(0), nil
+		}
+	}
+	p.ops = append(p.ops, op)
+}
+
+// 返回值为 bool
+func (p *Pipeline) Exists(key string) {
+	p.ops = append(p.ops, pipelineOp{
+		keys:  []string{key},
+		cmd:   "EXISTS",
+		args:  []interface{}{key},
+		parse: parseBool,
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.Exists(ctx, key)
+		},
+	})
+}
+
+// 返回值为 bool
+func (p *Pipeline) Expire(key string, ttl time.Duration) {
+	var err error
+	if ttl <= 0 {
+		err = ErrInvalidTTL
+	}
+	p.ops = append(p.ops, pipelineOp{
+		write: true,
+		keys:  []string{key},
+		cmd:   "PEXPIRE",
+		args:  []interface{}{key, roundTTL(ttl).Milliseconds()},
+		err:   err,
+		parse: parseBool,
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.Expire(ctx, key, ttl)
+		},
+	})
+}
+
+// 返回值为 time.Duration
+func (p *Pipeline) TTL(key string) {
+	p.ops = append(p.ops, pipelineOp{
+		keys: []string{key},
+		cmd:  "PTTL",
+		args: []interface{}{key},
+		parse: func(reply interface{}, err error) (interface{}, error) {
+			ms, err := redigo.Int64(reply, err)
+			if err != nil {
+				return time.Duration(0), err
+			}
+			return durationOf(ms), nil
+		},
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.TTL(ctx, key)
+		},
+	})
+}
+
+// 返回值为 int64
+func (p *Pipeline) Incr(key string) {
+	p.IncrBy(key, 1)
+}
+
+// 返回值为 int64
+func (p *Pipeline) IncrBy(key string, n int64) {
+	p.ops = append(p.ops, pipelineOp{
+		write: true,
+		keys:  []string{key},
+		cmd:   "INCRBY",
+		args:  []interface{}{key, n},
+		parse: parseInt64,
+		call: func(ctx context.Context, c Cache) (interface{}, error) {
+			return c.IncrBy(ctx, key, n)
+		},
+	})
+}
+
+// 返回值为 int64
+func (p *Pipeline) Decr(key string) {
+	p.IncrBy(key, -1)
+}
+
+// 执行并清空已入队的命令，结果与入队顺序一致
+//
+// 单条命令失败记录在对应结果中；连接失败等无法获得结果时返回错误
+func (p *Pipeline) Exec(ctx context.Context) ([]PipelineResult, error) {
+	ops := p.ops
+	p.ops = nil
+	if len(ops) == 0 {
+		return []PipelineResult{}, nil
+	}
+	return execPipeline(ctx, p.cache, ops)
+}
+
+func execPipeline(ctx context.Context, c Cache, ops []pipelineOp) ([]PipelineResult, error) {
+	if e, ok := c.(pipeliner); ok {
+		return e.execPipeline(ctx, ops)
+	}
+	results := make([]PipelineResult, len(ops))
+	for i, op := range ops {
+		if op.err != nil {
+			results[i].Reply, results[i].Err = op.parse(nil, op.err)
+			continue
+		}
+		results[i].Reply, results[i].Err = op.call(ctx, c)
+	}
+	return results, nil
+}
+
+// 一次发送全部命令后依次读取结果，调用超时时间作用于整批命令
+func (c *RedisCache) execPipeline(ctx context.Context, ops []pipelineOp) ([]PipelineResult, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	r, err := c.conn(ctx, c.Pool)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, op := range ops {
+		if op.err != nil || len(op.cmd) == 0 {
+			continue
+		}
+		if err := r.Send(op.cmd, op.args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.Flush(); err != nil {
+		return nil, err
+	}
+	results := make([]PipelineResult, len(ops))
+	for i, op := range ops {
+		if op.err != nil || len(op.cmd) == 0 {
+			results[i].Reply, results[i].Err = op.parse(nil, op.err)
+			continue
+		}
+		reply, err := receiveContext(ctx, r)
+		if _, ok := err.(redigo.Error); err != nil && !ok {
+			return nil, err
+		}
+		results[i].Reply, results[i].Err = op.parse(reply, err)
+	}
+	return results, nil
+}
+
+// 在远端执行，完成后移除写入过的键的本地缓存
+func (c *TieredCache) execPipeline(ctx context.Context, ops []pipelineOp) ([]PipelineResult, error) {
+	results, err := c.Remote.execPipeline(ctx, ops)
+	var keys []string
+	for _, op := range ops {
+		if op.write {
+			keys = append(keys, op.keys...)
+		}
+	}
+	if len(keys) > 0 {
+		c.invalidate(ctx, keys...)
+	}
+	return results, err
+}
+
+func (c *hookedCache) execPipeline(ctx context.Context, ops []pipelineOp) ([]PipelineResult, error) {
+	start := time.Now()
+	results, err := execPipeline(ctx, c.next, ops)
+	var keys []string
+	for _, op := range ops {
+		keys = append(keys, op.keys...)
+	}
+	c.emit(ctx, "pipeline", keys, start, SUCCESS, err)
+	return results, err
+}
+
+func parseReply(reply interface{}, err error) (interface{}, error) {
+	return reply, err
+}
+
+func parseInt64(reply interface{}, err error) (interface{}, error) {
+	return redigo.Int64(reply, err)
+}
+
+func parseBool(reply interface{}, err error) (interface{}, error) {
+	return redigo.Bool(reply, err)
+}
+
+// 使用默认对象执行批量操作
+func Pipelined(ctx context.Context, fn func(p *Pipeline)) ([]PipelineResult, error) {
+	p := NewPipeline(Default())
+	fn(p)
+	return p.Exec(ctx)
+}
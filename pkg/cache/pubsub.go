@@ -0,0 +1,434 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/redisconn"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_SUBSCRIBE_BUFFER = 100
+)
+
+// 发布与订阅
+type PubSub interface {
+	Publish(ctx context.Context, channel string, message interface{}) (int64, error)
+	Subscribe(ctx context.Context, channels ...string) (*Subscription, error)
+	PSubscribe(ctx context.Context, patterns ...string) (*Subscription, error)
+	SubscribeExpired(ctx context.Context) (*Subscription, error)
+}
+
+// 订阅收到的消息
+type Message struct {
+	Channel string `json:"channel" label:"频道"`
+	Pattern string `json:"pattern" label:"订阅模式" desc:"按模式订阅时使用"`
+	Data    []byte `json:"data" label:"内容"`
+}
+
+// 订阅时所用参数
+type SubscribeOption struct {
+	Channels    []string                              `json:"channels" label:"频道"`
+	Patterns    []string                              `json:"patterns" label:"频道模式"`
+	Buffer      int                                   `json:"buffer" label:"消息缓冲数量" desc:"默认100，缓冲已满时暂停读取"`
+	OnSubscribe func(ctx context.Context)             `json:"-" label:"订阅成功回调" desc:"首次订阅与重连后重新订阅成功时调用，断开期间的消息不会补发"`
+	allNodes    bool                                  // 集群模式下订阅全部节点
+	filter      func(message Message) (Message, bool) // 转换或丢弃消息
+}
+
+// 结构体
+// 订阅，断开后自动重连并重新订阅，ctx 结束或调用 Close 后关闭消息通道
+type Subscription struct {
+	messages chan Message
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// 消息通道，订阅结束后关闭
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// 结束订阅并等待关闭
+func (s *Subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// 订阅结束时关闭
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+func pubSubOf(c Cache) (PubSub, error) {
+	p, ok := c.(PubSub)
+	if !ok {
+		return nil, errors.New("缓存对象不支持发布与订阅")
+	}
+	return p, nil
+}
+
+// 发布消息，返回收到消息的订阅数量
+func (c *RedisCache) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	data, err := encodeValue(c.codec, message)
+	if err != nil {
+		return 0, err
+	}
+	return redigo.Int64(c.do(ctx, "PUBLISH", channel, data))
+}
+
+func (c *RedisCache) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	return c.SubscribeWith(ctx, SubscribeOption{Channels: channels})
+}
+
+func (c *RedisCache) PSubscribe(ctx context.Context, patterns ...string) (*Subscription, error) {
+	return c.SubscribeWith(ctx, SubscribeOption{Patterns: patterns})
+}
+
+// 订阅键过期事件，消息内容为过期的键名
+//
+// 需要服务端的 notify-keyspace-events 包含 Ex，集群模式下订阅全部节点
+func (c *RedisCache) SubscribeExpired(ctx context.Context) (*Subscription, error) {
+	return c.SubscribeWith(ctx, SubscribeOption{
+		Channels: []string{fmt.Sprintf("__keyevent@%d__:expired", c.db)},
+		allNodes: true,
+	})
+}
+
+// 按参数订阅，首次连接失败时返回错误，之后断开时自动重连
+func (c *RedisCache) SubscribeWith(ctx context.Context, option SubscribeOption) (*Subscription, error) {
+	option.Channels, option.Patterns = distinct(option.Channels), distinct(option.Patterns)
+	if len(option.Channels)+len(option.Patterns) == 0 {
+		return nil, errors.New("频道与频道模式不能同时为空")
+	}
+	if option.Buffer <= 0 {
+		option.Buffer = DEFAULT_SUBSCRIBE_BUFFER
+	}
+	pools := []*redigo.Pool{c.Pool}
+	if option.allNodes {
+		nodes, err := redisconn.Nodes(c.Pool)
+		if err != nil {
+			return nil, err
+		}
+		pools = pools[:0]
+		for _, node := range nodes {
+			pools = append(pools, node.Pool)
+		}
+	}
+	if err := c.track(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	// 缓存对象关闭时结束订阅
+	go func() {
+		select {
+		case <-c.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	conns := make([]redigo.PubSubConn, 0, len(pools))
+	for _, pool := range pools {
+		psc, err := subscribe(ctx, pool, option)
+		if err != nil {
+			for _, psc := range conns {
+				psc.Close()
+			}
+			cancel()
+			c.subs.Done()
+			return nil, err
+		}
+		conns = append(conns, psc)
+	}
+	s := &Subscription{
+		messages: make(chan Message, option.Buffer),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	var wg sync.WaitGroup
+	for i := range pools {
+		wg.Add(1)
+		go func(pool *redigo.Pool, psc redigo.PubSubConn) {
+			defer wg.Done()
+			s.listen(ctx, pool, psc, option)
+		}(pools[i], conns[i])
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(s.messages)
+		close(s.done)
+		c.subs.Done()
+	}()
+	return s, nil
+}
+
+func subscribe(ctx context.Context, pool *redigo.Pool, option SubscribeOption) (redigo.PubSubConn, error) {
+	r, err := getConn(ctx, pool)
+	if err != nil {
+		return redigo.PubSubConn{}, err
+	}
+	psc := redigo.PubSubConn{Conn: r}
+	if len(option.Channels) > 0 {
+		if err := psc.Subscribe(redigo.Args{}.AddFlat(option.Channels)...); err != nil {
+			psc.Close()
+			return redigo.PubSubConn{}, err
+		}
+	}
+	if len(option.Patterns) > 0 {
+		if err := psc.PSubscribe(redigo.Args{}.AddFlat(option.Patterns)...); err != nil {
+			psc.Close()
+			return redigo.PubSubConn{}, err
+		}
+	}
+	return psc, nil
+}
+
+// 持续读取消息，断开后按间隔重连，间隔逐次翻倍
+func (s *Subscription) listen(ctx context.Context, pool *redigo.Pool, psc redigo.PubSubConn, option SubscribeOption) {
+	interval := DEFAULT_RECONNECT_INTERVAL
+	for {
+		start := time.Now()
+		s.receive(ctx, psc, option)
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Since(start) > MAX_RECONNECT_INTERVAL {
+				interval = DEFAULT_RECONNECT_INTERVAL
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			if interval *= 2; interval > MAX_RECONNECT_INTERVAL {
+				interval = MAX_RECONNECT_INTERVAL
+			}
+			var err error
+			if psc, err = subscribe(ctx, pool, option); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// 读取直至断开或 ctx 结束，定期发送PING检查连接
+func (s *Subscription) receive(ctx context.Context, psc redigo.PubSubConn, option SubscribeOption) error {
+	defer psc.Close()
+	total := len(option.Channels) + len(option.Patterns)
+	done := make(chan error, 1)
+	go func() {
+		for {
+			// 超过两个检查间隔未收到任何回复时视为断开
+			switch n := psc.ReceiveWithTimeout(2 * DEFAULT_HEALTH_INTERVAL).(type) {
+			case error:
+				done <- n
+				return
+			case redigo.Message:
+				message := Message{Channel: n.Channel, Pattern: n.Pattern, Data: n.Data}
+				if option.filter != nil {
+					var ok bool
+					if message, ok = option.filter(message); !ok {
+						continue
+					}
+				}
+				select {
+				case s.messages <- message:
+				case <-ctx.Done():
+					done <- ctx.Err()
+					return
+				}
+			case redigo.Subscription:
+				if n.Count == 0 {
+					done <- nil
+					return
+				}
+				if n.Count == total && (n.Kind == "subscribe" || n.Kind == "psubscribe") && option.OnSubscribe != nil {
+					option.OnSubscribe(ctx)
+				}
+			}
+		}
+	}()
+	ticker := time.NewTicker(DEFAULT_HEALTH_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// 写入失败时连接被关闭，读取协程随即退出
+			if err := psc.Ping(""); err != nil {
+				<-done
+				return err
+			}
+		case <-ctx.Done():
+			psc.Unsubscribe()
+			psc.PUnsubscribe()
+			<-done
+			return ctx.Err()
+		case err := <-done:
+			if err == nil {
+				err = errors.New("订阅已取消")
+			}
+			return err
+		}
+	}
+}
+
+// 获取被包装的Redis缓存对象，不基于Redis时返回nil
+func remoteOf(c Cache) *RedisCache {
+	switch c := Unwrap(c).(type) {
+	case *RedisCache:
+		return c
+	case *TieredCache:
+		return c.Remote
+	default:
+		return nil
+	}
+}
+
+func distinct(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func (c *TieredCache) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	return c.Remote.Publish(ctx, channel, message)
+}
+
+func (c *TieredCache) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	return c.Remote.Subscribe(ctx, channels...)
+}
+
+func (c *TieredCache) PSubscribe(ctx context.Context, patterns ...string) (*Subscription, error) {
+	return c.Remote.PSubscribe(ctx, patterns...)
+}
+
+func (c *TieredCache) SubscribeExpired(ctx context.Context) (*Subscription, error) {
+	return c.Remote.SubscribeExpired(ctx)
+}
+
+// 频道不添加前缀
+func (c *namespacedCache) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	p, err := pubSubOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	return p.Publish(ctx, channel, message)
+}
+
+func (c *namespacedCache) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	p, err := pubSubOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return p.Subscribe(ctx, channels...)
+}
+
+func (c *namespacedCache) PSubscribe(ctx context.Context, patterns ...string) (*Subscription, error) {
+	p, err := pubSubOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return p.PSubscribe(ctx, patterns...)
+}
+
+// 仅保留带前缀的键，消息内容为去除前缀后的键名
+func (c *namespacedCache) SubscribeExpired(ctx context.Context) (*Subscription, error) {
+	r := remoteOf(c)
+	if r == nil {
+		return nil, errors.New("缓存对象不支持发布与订阅")
+	}
+	return r.SubscribeWith(ctx, SubscribeOption{
+		Channels: []string{fmt.Sprintf("__keyevent@%d__:expired", r.db)},
+		allNodes: true,
+		filter: func(message Message) (Message, bool) {
+			key := string(message.Data)
+			if !strings.HasPrefix(key, c.prefix) {
+				return message, false
+			}
+			message.Data = []byte(strings.TrimPrefix(key, c.prefix))
+			return message, true
+		},
+	})
+}
+
+func (c *hookedCache) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	p, err := pubSubOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	n, err := p.Publish(ctx, channel, message)
+	c.emit(ctx, "publish", nil, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	p, err := pubSubOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return p.Subscribe(ctx, channels...)
+}
+
+func (c *hookedCache) PSubscribe(ctx context.Context, patterns ...string) (*Subscription, error) {
+	p, err := pubSubOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return p.PSubscribe(ctx, patterns...)
+}
+
+func (c *hookedCache) SubscribeExpired(ctx context.Context) (*Subscription, error) {
+	p, err := pubSubOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	return p.SubscribeExpired(ctx)
+}
+
+func Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	p, err := pubSubOf(Default())
+	if err != nil {
+		return 0, err
+	}
+	return p.Publish(ctx, channel, message)
+}
+
+func Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	p, err := pubSubOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return p.Subscribe(ctx, channels...)
+}
+
+func PSubscribe(ctx context.Context, patterns ...string) (*Subscription, error) {
+	p, err := pubSubOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return p.PSubscribe(ctx, patterns...)
+}
+
+func SubscribeExpired(ctx context.Context) (*Subscription, error) {
+	p, err := pubSubOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return p.SubscribeExpired(ctx)
+}
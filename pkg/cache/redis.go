@@ -3,9 +3,14 @@ package cache
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/aivencs/magic-box/pkg/lifecycle"
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/redisconn"
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 	redigo "github.com/gomodule/redigo/redis"
 )
@@ -14,165 +19,526 @@ import (
 type SupportType string
 
 const (
-	REDIS SupportType = "redis"
+	REDIS  SupportType = "redis"
+	MEMORY SupportType = "memory"
+	TIERED SupportType = "tiered"
 	// 定义默认值
-	DEFAULT_MAXIDLE      = 20
-	DEFAULT_IDLE_TIMEOUT = 120 * time.Second
-	DEFAULT_MAXACTIVE    = 100
+	DEFAULT_MAXIDLE      = redisconn.DEFAULT_MAXIDLE
+	DEFAULT_IDLE_TIMEOUT = redisconn.DEFAULT_IDLE_TIMEOUT
+	DEFAULT_MAXACTIVE    = redisconn.DEFAULT_MAXACTIVE
+	// 剩余有效期的特殊值，与Redis的PTTL一致
+	TTL_MISSING    time.Duration = -2 // 键不存在或已过期
+	TTL_PERSISTENT time.Duration = -1 // 键未设置有效期
 )
 
+// 缓存对象已关闭
+var ErrClosed = errors.New("缓存对象已关闭")
+var ErrInvalidTTL = errors.New("有效期必须大于0")
+
 // 定义全局配置对象
-var cache Cache
-var once sync.Once
+var instances = map[string]Cache{}
+var mu sync.RWMutex
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
 
 func init() {
-	ctx := context.WithValue(context.Background(), "trace", "init-for-config")
+	ctx := trace.WithTrace(context.Background(), "init-for-config")
 	validate.InitValidate(ctx, "validator", validate.Option{})
 }
 
 // 抽象接口
+//
+// Overdue 保持原有含义，剩余有效期超过1秒时为 true，键不存在或未设置有效期时均为 false
 type Cache interface {
 	Get(ctx context.Context, key string) (interface{}, error)
 	Set(ctx context.Context, key string, value interface{}) (interface{}, error)
-	Overdue(ctx context.Context, key interface{}) bool
+	Overdue(ctx context.Context, key string) (bool, error)
 	SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error)
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) (int64, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Incr(ctx context.Context, key string) (int64, error)
+	IncrBy(ctx context.Context, key string, n int64) (int64, error)
+	Decr(ctx context.Context, key string) (int64, error)
+	MGet(ctx context.Context, keys ...string) ([]interface{}, error)
+	MSet(ctx context.Context, values map[string]interface{}) error
+	Close(ctx context.Context) error
 }
 
 // 初始化时所用参数
 type Option struct {
-	Host        string        `json:"host" label:"服务地址" validate:"required"`
-	Auth        bool          `json:"auth" label:"是否鉴权" desc:"默认不鉴权"`
-	Username    string        `json:"username" label:"用户名"`
-	Password    string        `json:"password" label:"密码"`
-	Database    string        `json:"database" label:"数据库"`
-	Table       string        `json:"table" label:"数据表"`
-	DB          int           `json:"db" label:"数据库"`
-	MaxIdle     int           `json:"max_idle" label:"最大空闲链接数"`
-	IdleTimeout time.Duration `json:"idle_timeout" label:"空闲超时时间"`
-	MaxActive   int           `json:"max_active" label:"最大链接数"`
-}
-
-// 初始化对象
+	Host             string              `json:"host" label:"服务地址" desc:"Redis使用"`
+	Auth             bool                `json:"auth" label:"是否鉴权" desc:"默认不鉴权"`
+	Username         string              `json:"username" label:"用户名"`
+	Password         string              `json:"password" label:"密码"`
+	Database         string              `json:"database" label:"数据库"`
+	Table            string              `json:"table" label:"数据表"`
+	Prefix           string              `json:"prefix" label:"键前缀" desc:"自动添加到全部键，例如application:env:"`
+	DB               int                 `json:"db" label:"数据库"`
+	MaxIdle          int                 `json:"max_idle" label:"最大空闲链接数"`
+	IdleTimeout      time.Duration       `json:"idle_timeout" label:"空闲超时时间"`
+	MaxActive        int                 `json:"max_active" label:"最大链接数"`
+	MaxEntries       int                 `json:"max_entries" label:"最大键数量" desc:"内存缓存使用，默认10000"`
+	MaxBytes         int64               `json:"max_bytes" label:"最大占用字节数" desc:"内存缓存使用，为0时不限制"`
+	LocalTTL         time.Duration       `json:"local_ttl" label:"本地缓存有效期" desc:"两级缓存使用，默认60秒"`
+	Channel          string              `json:"channel" label:"失效通知频道" desc:"两级缓存使用，同一组实例需相同"`
+	Codec            CodecType           `json:"codec" label:"序列化方式" desc:"写入非基础类型的值时使用，默认为json"`
+	Mode             redisconn.Mode      `json:"mode" label:"连接方式" desc:"默认为单节点，可选sentinel与cluster"`
+	Addrs            []string            `json:"addrs" label:"服务地址列表" desc:"哨兵或集群使用，为空时使用服务地址"`
+	MasterName       string              `json:"master_name" label:"主节点名称" desc:"哨兵使用"`
+	SentinelPassword string              `json:"sentinel_password" label:"哨兵密码"`
+	TLS              redisconn.TLSOption `json:"tls" label:"TLS参数"`
+	DialTimeout      time.Duration       `json:"dial_timeout" label:"连接超时时间" desc:"为0时不限制"`
+	ReadTimeout      time.Duration       `json:"read_timeout" label:"读取超时时间" desc:"为0时不限制"`
+	WriteTimeout     time.Duration       `json:"write_timeout" label:"写入超时时间" desc:"为0时不限制"`
+	Timeout          time.Duration       `json:"timeout" label:"调用超时时间" desc:"ctx 未设置截止时间时使用，包含等待空闲连接的时间，为0时不限制"`
+}
+
+// 初始化默认对象，重复调用时替换默认对象
 func InitCache(ctx context.Context, name SupportType, option Option) error {
-	var c = cache
-	var err error
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Cache, error) {
 	message, err := validate.Work(ctx, option)
 	if err != nil {
-		return errors.New(message)
+		return nil, errors.New(message)
 	}
-	once.Do(func() {
-		c = CacheFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		cache = c
-	})
-	return err
+	c, err := CacheFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
 }
 
-// 抽象工厂
-func CacheFactory(ctx context.Context, name SupportType, option Option) Cache {
+// 注册命名对象，名称已存在时替换并关闭原对象，进程退出时由 lifecycle 关闭
+func Register(key string, c Cache) {
+	mu.Lock()
+	replaced := instances[key]
+	instances[key] = c
+	mu.Unlock()
+	if replaced != nil && replaced != c {
+		loaders.Delete(replaced)
+	}
+	// 关闭原对象可能耗时较长，释放锁后再注册，避免阻塞获取对象
+	lifecycle.Register("cache:"+key, c)
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Cache {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Cache {
+	return Use(DEFAULT_INSTANCE)
+}
+
+// 抽象工厂，设置了键前缀时为全部键添加前缀
+func CacheFactory(ctx context.Context, name SupportType, option Option) (Cache, error) {
+	var c Cache
+	var err error
 	switch name {
 	case REDIS:
-		return NewRedisCache(ctx, option)
+		c, err = NewRedisCache(ctx, option)
+	case MEMORY:
+		c, err = NewMemoryCache(ctx, option)
+	case TIERED:
+		c, err = NewTieredCache(ctx, option)
 	default:
-		return NewRedisCache(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %s", name)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return Namespace(option.Prefix)(c), nil
 }
 
 // 结构体
 // 基于Redis
 type RedisCache struct {
-	Pool *redigo.Pool
+	Pool    *redigo.Pool
+	codec   Codec
+	db      int
+	timeout time.Duration
+	closing chan struct{} // 关闭时关闭，用于结束订阅
+	closed  bool
+	subs    sync.WaitGroup // 进行中的订阅
+	mu      sync.Mutex
 }
 
-func applyOption(option Option) {
-	if option.MaxIdle == 0 {
-		option.MaxIdle = DEFAULT_MAXIDLE
-	}
-	if option.IdleTimeout == 0 {
-		option.IdleTimeout = DEFAULT_IDLE_TIMEOUT
+// 转换为连接参数，未设置服务地址列表时使用服务地址
+func connOption(option Option) redisconn.Option {
+	addrs := option.Addrs
+	if len(addrs) == 0 && len(option.Host) > 0 {
+		addrs = []string{option.Host}
 	}
-	if option.MaxActive == 0 {
-		option.MaxActive = DEFAULT_MAXACTIVE
+	return redisconn.Option{
+		Mode:             option.Mode,
+		Addrs:            addrs,
+		MasterName:       option.MasterName,
+		SentinelPassword: option.SentinelPassword,
+		Auth:             option.Auth,
+		Username:         option.Username,
+		Password:         option.Password,
+		DB:               option.DB,
+		TLS:              option.TLS,
+		DialTimeout:      option.DialTimeout,
+		ReadTimeout:      option.ReadTimeout,
+		WriteTimeout:     option.WriteTimeout,
+		MaxIdle:          option.MaxIdle,
+		IdleTimeout:      option.IdleTimeout,
+		MaxActive:        option.MaxActive,
 	}
 }
 
 // 创建基于Redis的对象
-func NewRedisCache(ctx context.Context, option Option) Cache {
-	applyOption(option)
-	pool := &redigo.Pool{
-		MaxIdle:     option.MaxIdle,
-		IdleTimeout: option.IdleTimeout,
-		MaxActive:   option.MaxActive,
-		Wait:        true,
-		Dial: func() (redigo.Conn, error) {
-			c, err := redigo.Dial("tcp", option.Host)
-			if err != nil {
-				return nil, err
-			}
-			if option.Auth {
-				if _, err := c.Do("AUTH", option.Password); err != nil {
-					c.Close()
-					return nil, err
-				}
-				if _, err := c.Do("SELECT", option.DB); err != nil {
-					c.Close()
-					return nil, err
-				}
-			}
-			return c, err
-		},
-		TestOnBorrow: func(c redigo.Conn, t time.Time) error {
-			_, err := c.Do("PING")
-			return err
-		},
+func NewRedisCache(ctx context.Context, option Option) (Cache, error) {
+	codec, err := CodecFactory(option.Codec)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := redisconn.NewPool(ctx, connOption(option))
+	if err != nil {
+		return nil, err
 	}
 	return &RedisCache{
-		Pool: pool,
+		Pool:    pool,
+		codec:   codec,
+		db:      option.DB,
+		timeout: option.Timeout,
+		closing: make(chan struct{}),
+	}, nil
+}
+
+// 出错时返回值为 nil，与内存缓存一致
+func (c *RedisCache) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	r, err := c.conn(ctx, c.Pool)
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
+	return doContext(ctx, r, cmd, args...)
+}
+
+// ctx 未设置截止时间时使用调用超时时间
+func (c *RedisCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// 在已获取的连接上执行，使用调用超时时间
+func (c *RedisCache) doConn(ctx context.Context, r redigo.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return doContext(ctx, r, cmd, args...)
+}
+
+// 获取连接，已关闭时返回 ErrClosed
+func (c *RedisCache) conn(ctx context.Context, pool *redigo.Pool) (redigo.Conn, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	return getConn(ctx, pool)
+}
+
+func (c *RedisCache) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// 获取连接，连接池已满时等待至 ctx 结束，超时返回 TIMEOUT
+func getConn(ctx context.Context, pool *redigo.Pool) (redigo.Conn, error) {
+	r, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, logger.WrapTimeout(err, logger.TIMEOUT)
+	}
+	return r, nil
+}
+
+// 按 ctx 的截止时间执行，ctx 结束时中断等待，超时返回 CALLTIMEOUT
+func doContext(ctx context.Context, r redigo.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	var reply interface{}
+	var err error
+	if ctx.Done() == nil {
+		// 不会结束的 ctx 无需额外的协程等待
+		reply, err = r.Do(cmd, args...)
+	} else {
+		reply, err = redigo.DoContext(r, ctx, cmd, args...)
+	}
+	if err != nil {
+		return nil, logger.WrapTimeout(err, logger.CALLTIMEOUT)
+	}
+	return reply, nil
+}
+
+// 与 doContext 一致，用于读取批量发送的命令的结果
+func receiveContext(ctx context.Context, r redigo.Conn) (interface{}, error) {
+	var reply interface{}
+	var err error
+	if ctx.Done() == nil {
+		reply, err = r.Receive()
+	} else {
+		reply, err = redigo.ReceiveContext(r, ctx)
+	}
+	return reply, logger.WrapTimeout(err, logger.CALLTIMEOUT)
 }
 
 func (c *RedisCache) Get(ctx context.Context, key string) (interface{}, error) {
-	r := c.Pool.Get()
-	defer r.Close()
-	return r.Do("GET", key)
+	return c.do(ctx, "GET", key)
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, value interface{}) (interface{}, error) {
-	r := c.Pool.Get()
-	defer r.Close()
-	return r.Do("SET", key, value)
+	data, err := encodeValue(c.codec, value)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, "SET", key, data)
 }
 
-func (c *RedisCache) Overdue(ctx context.Context, key interface{}) bool {
-	r := c.Pool.Get()
-	defer r.Close()
-	res, err := r.Do("TTL", key)
+// 剩余有效期超过1秒时为 true，键不存在或未设置有效期时为 false；判断键是否存在使用 Exists
+func (c *RedisCache) Overdue(ctx context.Context, key string) (bool, error) {
+	ttl, err := c.TTL(ctx, key)
+	return ttl > time.Second, err
+}
+
+func (c *RedisCache) SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error) {
+	data, err := encodeValue(c.codec, value)
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return res.(int64) > 1
+	return c.do(ctx, "SETEX", key, sec, data)
 }
 
-func (c *RedisCache) SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error) {
-	r := c.Pool.Get()
-	defer r.Close()
-	return r.Do("SETEX", key, sec, value)
+// 键不存在时写入，ttl 为0时不过期，小于0时返回 ErrInvalidTTL
+func (c *RedisCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	if ttl < 0 {
+		return false, ErrInvalidTTL
+	}
+	data, err := encodeValue(c.codec, value)
+	if err != nil {
+		return false, err
+	}
+	args := redigo.Args{key, data, "NX"}
+	if ttl > 0 {
+		args = args.Add("PX", roundTTL(ttl).Milliseconds())
+	}
+	reply, err := c.do(ctx, "SET", args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// 结束全部订阅后关闭连接池，ctx 结束时不再等待订阅，重复调用时直接返回
+func (c *RedisCache) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.closing)
+	c.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		c.subs.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = logger.WrapTimeout(ctx.Err(), logger.TIMEOUT)
+	}
+	if e := redisconn.Close(c.Pool); err == nil {
+		err = e
+	}
+	return err
+}
+
+// 记录新的订阅，已关闭时返回 ErrClosed
+func (c *RedisCache) track() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	c.subs.Add(1)
+	return nil
+}
+
+// 连接池统计
+func (c *RedisCache) PoolStats() redisconn.Stats {
+	return redisconn.StatsOf(c.Pool)
+}
+
+// 健康检查
+func (c *RedisCache) Health(ctx context.Context) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	return redisconn.Health(ctx, c.Pool)
+}
+
+func (c *RedisCache) Codec() Codec {
+	return c.codec
+}
+
+// 删除键，返回实际删除的数量
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return redigo.Int64(c.do(ctx, "DEL", redigo.Args{}.AddFlat(keys)...))
+}
+
+func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
+	return redigo.Bool(c.do(ctx, "EXISTS", key))
+}
+
+// 设置有效期，键不存在时返回 false，ttl 不大于0时返回 ErrInvalidTTL
+func (c *RedisCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, ErrInvalidTTL
+	}
+	return redigo.Bool(c.do(ctx, "PEXPIRE", key, roundTTL(ttl).Milliseconds()))
+}
+
+// 有效期以毫秒写入Redis，不足1毫秒时按1毫秒处理，避免写入0时删除键
+func roundTTL(ttl time.Duration) time.Duration {
+	if ttl < time.Millisecond {
+		return time.Millisecond
+	}
+	return ttl.Truncate(time.Millisecond)
+}
+
+// 剩余有效期，精确到毫秒，键不存在时为 TTL_MISSING，未设置有效期时为 TTL_PERSISTENT
+func (c *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ms, err := redigo.Int64(c.do(ctx, "PTTL", key))
+	if err != nil {
+		return 0, err
+	}
+	return durationOf(ms), nil
+}
+
+func (c *RedisCache) Incr(ctx context.Context, key string) (int64, error) {
+	return redigo.Int64(c.do(ctx, "INCR", key))
+}
+
+func (c *RedisCache) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return redigo.Int64(c.do(ctx, "INCRBY", key, n))
+}
+
+func (c *RedisCache) Decr(ctx context.Context, key string) (int64, error) {
+	return redigo.Int64(c.do(ctx, "DECR", key))
+}
+
+// 批量读取，不存在的键对应 nil
+func (c *RedisCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	if len(keys) == 0 {
+		return []interface{}{}, nil
+	}
+	return redigo.Values(c.do(ctx, "MGET", redigo.Args{}.AddFlat(keys)...))
+}
+
+// 批量写入，集群模式下键位于不同槽位时按槽位拆分，不保证原子性
+func (c *RedisCache) MSet(ctx context.Context, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	args := redigo.Args{}
+	for key, value := range values {
+		data, err := encodeValue(c.codec, value)
+		if err != nil {
+			return err
+		}
+		args = args.Add(key, data)
+	}
+	_, err := c.do(ctx, "MSET", args...)
+	return err
+}
+
+// 将PTTL的返回值转换为剩余有效期
+func durationOf(ms int64) time.Duration {
+	if ms < 0 {
+		return time.Duration(ms)
+	}
+	return time.Duration(ms) * time.Millisecond
 }
 
 func Get(ctx context.Context, key string) (interface{}, error) {
-	return cache.Get(ctx, key)
+	return Default().Get(ctx, key)
 }
 
 func Set(ctx context.Context, key string, value interface{}) (interface{}, error) {
-	return cache.Set(ctx, key, value)
+	return Default().Set(ctx, key, value)
 }
 
 func SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error) {
-	return cache.SetEx(ctx, key, value, sec)
+	return Default().SetEx(ctx, key, value, sec)
+}
+
+func Overdue(ctx context.Context, key string) (bool, error) {
+	return Default().Overdue(ctx, key)
+}
+
+func SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	return Default().SetNX(ctx, key, value, ttl)
+}
+
+func Delete(ctx context.Context, keys ...string) (int64, error) {
+	return Default().Delete(ctx, keys...)
+}
+
+func Exists(ctx context.Context, key string) (bool, error) {
+	return Default().Exists(ctx, key)
+}
+
+func Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return Default().Expire(ctx, key, ttl)
+}
+
+func TTL(ctx context.Context, key string) (time.Duration, error) {
+	return Default().TTL(ctx, key)
+}
+
+func Incr(ctx context.Context, key string) (int64, error) {
+	return Default().Incr(ctx, key)
+}
+
+func IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return Default().IncrBy(ctx, key, n)
+}
+
+func Decr(ctx context.Context, key string) (int64, error) {
+	return Default().Decr(ctx, key)
+}
+
+func MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	return Default().MGet(ctx, keys...)
+}
+
+func MSet(ctx context.Context, values map[string]interface{}) error {
+	return Default().MSet(ctx, values)
 }
 
-func Overdue(ctx context.Context, key interface{}) bool {
-	return cache.Overdue(ctx, key)
+func Close(ctx context.Context) error {
+	return Default().Close(ctx)
 }
@@ -0,0 +1,840 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// 与Redis保持一致的错误
+var errWrongType = redigo.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// 哈希
+type HashCache interface {
+	HGet(ctx context.Context, key string, field string) (interface{}, error)
+	HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error)
+	HGetAll(ctx context.Context, key string) (map[string][]byte, error)
+}
+
+// 列表
+type ListCache interface {
+	LPush(ctx context.Context, key string, values ...interface{}) (int64, error)
+	RPop(ctx context.Context, key string) (interface{}, error)
+	BRPop(ctx context.Context, key string, timeout time.Duration) (interface{}, error)
+}
+
+// 集合
+type SetCache interface {
+	SAdd(ctx context.Context, key string, members ...interface{}) (int64, error)
+	SIsMember(ctx context.Context, key string, member interface{}) (bool, error)
+}
+
+// 有序集合
+type SortedSetCache interface {
+	ZAdd(ctx context.Context, key string, members ...Z) (int64, error)
+	ZRangeByScore(ctx context.Context, key string, min, max float64) ([]Z, error)
+	ZPopMin(ctx context.Context, key string, count int64) ([]Z, error)
+}
+
+// 支持全部数据结构的缓存对象
+type StructureCache interface {
+	HashCache
+	ListCache
+	SetCache
+	SortedSetCache
+}
+
+// 有序集合的成员
+//
+// 成员按与其他数据结构相同的规则编码，字符串原样写入，Redis与内存缓存读取时均原样返回
+type Z struct {
+	Member string  `json:"member" label:"成员"`
+	Score  float64 `json:"score" label:"分数"`
+}
+
+func structureOf(c Cache) (StructureCache, error) {
+	s, ok := c.(StructureCache)
+	if !ok {
+		return nil, errors.New("缓存对象不支持数据结构操作")
+	}
+	return s, nil
+}
+
+// 读取哈希字段，不存在时返回 nil
+func (c *RedisCache) HGet(ctx context.Context, key string, field string) (interface{}, error) {
+	return c.do(ctx, "HGET", key, field)
+}
+
+// 写入哈希字段，返回新增字段的数量
+func (c *RedisCache) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	args := redigo.Args{key}
+	for field, value := range values {
+		data, err := encodeValue(c.codec, value)
+		if err != nil {
+			return 0, err
+		}
+		args = args.Add(field, data)
+	}
+	return redigo.Int64(c.do(ctx, "HSET", args...))
+}
+
+// 读取全部哈希字段，键不存在时返回空集合
+func (c *RedisCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	values, err := redigo.ByteSlices(c.do(ctx, "HGETALL", key))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		result[string(values[i])] = values[i+1]
+	}
+	return result, nil
+}
+
+// 从左侧写入，返回写入后的长度
+func (c *RedisCache) LPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	args, err := c.encodeArgs(key, values)
+	if err != nil {
+		return 0, err
+	}
+	return redigo.Int64(c.do(ctx, "LPUSH", args...))
+}
+
+// 从右侧取出，列表为空时返回 nil
+func (c *RedisCache) RPop(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "RPOP", key)
+}
+
+// 从右侧取出，列表为空时等待，超时返回 nil；timeout 按秒向上取整，为0时等待至 ctx 结束
+//
+// 连接设置了读取超时时间时，timeout 应小于读取超时时间
+func (c *RedisCache) BRPop(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	r, err := c.conn(ctx, c.Pool)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	sec := int64(math.Ceil(timeout.Seconds()))
+	values, err := redigo.Values(doContext(ctx, r, "BRPOP", key, sec))
+	if err == redigo.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, errors.New("返回值格式错误")
+	}
+	return values[1], nil
+}
+
+// 写入集合，返回新增成员的数量
+func (c *RedisCache) SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	args, err := c.encodeArgs(key, members)
+	if err != nil {
+		return 0, err
+	}
+	return redigo.Int64(c.do(ctx, "SADD", args...))
+}
+
+func (c *RedisCache) SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	data, err := encodeValue(c.codec, member)
+	if err != nil {
+		return false, err
+	}
+	return redigo.Bool(c.do(ctx, "SISMEMBER", key, data))
+}
+
+// 写入有序集合，已存在的成员更新分数，返回新增成员的数量
+func (c *RedisCache) ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	args := redigo.Args{key}
+	for _, member := range members {
+		data, err := encodeValue(c.codec, member.Member)
+		if err != nil {
+			return 0, err
+		}
+		args = args.Add(formatScore(member.Score), data)
+	}
+	return redigo.Int64(c.do(ctx, "ZADD", args...))
+}
+
+// 按分数从小到大读取 [min, max] 内的成员，可使用 math.Inf 表示不限
+func (c *RedisCache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]Z, error) {
+	return parseZ(c.do(ctx, "ZRANGEBYSCORE", key, formatScore(min), formatScore(max), "WITHSCORES"))
+}
+
+// 取出分数最小的 count 个成员，count 小于1时按1处理
+func (c *RedisCache) ZPopMin(ctx context.Context, key string, count int64) ([]Z, error) {
+	if count < 1 {
+		count = 1
+	}
+	return parseZ(c.do(ctx, "ZPOPMIN", key, count))
+}
+
+func (c *RedisCache) encodeArgs(key string, values []interface{}) (redigo.Args, error) {
+	args := redigo.Args{key}
+	for _, value := range values {
+		data, err := encodeValue(c.codec, value)
+		if err != nil {
+			return nil, err
+		}
+		args = args.Add(data)
+	}
+	return args, nil
+}
+
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "+inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
+
+// 解析成员与分数交替的返回值
+func parseZ(reply interface{}, err error) ([]Z, error) {
+	values, err := redigo.Strings(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Z, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Z{Member: values[i], Score: score})
+	}
+	return result, nil
+}
+
+// 数据结构不经过本地缓存
+func (c *TieredCache) HGet(ctx context.Context, key string, field string) (interface{}, error) {
+	return c.Remote.HGet(ctx, key, field)
+}
+
+func (c *TieredCache) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	return c.Remote.HSet(ctx, key, values)
+}
+
+func (c *TieredCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	return c.Remote.HGetAll(ctx, key)
+}
+
+func (c *TieredCache) LPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	return c.Remote.LPush(ctx, key, values...)
+}
+
+func (c *TieredCache) RPop(ctx context.Context, key string) (interface{}, error) {
+	return c.Remote.RPop(ctx, key)
+}
+
+func (c *TieredCache) BRPop(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	return c.Remote.BRPop(ctx, key, timeout)
+}
+
+func (c *TieredCache) SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	return c.Remote.SAdd(ctx, key, members...)
+}
+
+func (c *TieredCache) SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	return c.Remote.SIsMember(ctx, key, member)
+}
+
+func (c *TieredCache) ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	return c.Remote.ZAdd(ctx, key, members...)
+}
+
+func (c *TieredCache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]Z, error) {
+	return c.Remote.ZRangeByScore(ctx, key, min, max)
+}
+
+func (c *TieredCache) ZPopMin(ctx context.Context, key string, count int64) ([]Z, error) {
+	return c.Remote.ZPopMin(ctx, key, count)
+}
+
+// 内存中的数据结构
+type memoryHash map[string][]byte
+type memorySet map[string]struct{}
+type memoryZSet map[string]float64
+type memoryList struct {
+	*list.List
+}
+
+// 获取指定类型的键，键不存在且 create 为 true 时创建，类型不符时返回错误
+func (c *MemoryCache) structure(key string, sample interface{}, create bool) (*memoryEntry, error) {
+	if entry, ok := c.lookup(key); ok {
+		if reflect.TypeOf(entry.data) != reflect.TypeOf(sample) {
+			return nil, errWrongType
+		}
+		return entry, nil
+	}
+	if !create {
+		return nil, nil
+	}
+	entry := &memoryEntry{key: key}
+	switch sample.(type) {
+	case memoryHash:
+		entry.data = memoryHash{}
+	case memorySet:
+		entry.data = memorySet{}
+	case memoryZSet:
+		entry.data = memoryZSet{}
+	case memoryList:
+		entry.data = memoryList{list.New()}
+	}
+	c.items[key] = c.order.PushFront(entry)
+	c.bytes += entry.size()
+	return entry, nil
+}
+
+// 调整数据结构占用的字节数，为空时删除键
+func (c *MemoryCache) resize(entry *memoryEntry, delta int64, empty bool) {
+	if empty {
+		c.remove(c.items[entry.key])
+		return
+	}
+	entry.extra += delta
+	c.bytes += delta
+	c.evict()
+}
+
+func (c *MemoryCache) HGet(ctx context.Context, key string, field string) (interface{}, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memoryHash(nil), false)
+	if entry == nil {
+		return nil, err
+	}
+	value, ok := entry.data.(memoryHash)[field]
+	if !ok {
+		return nil, nil
+	}
+	return cloneBytes(value), nil
+}
+
+func (c *MemoryCache) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	data := make(map[string][]byte, len(values))
+	for field, value := range values {
+		encoded, err := encodeValue(c.codec, value)
+		if err != nil {
+			return 0, err
+		}
+		data[field] = encoded
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memoryHash(nil), true)
+	if err != nil {
+		return 0, err
+	}
+	hash := entry.data.(memoryHash)
+	var added, delta int64
+	for field, value := range data {
+		if old, ok := hash[field]; ok {
+			delta -= int64(len(field) + len(old))
+		} else {
+			added++
+		}
+		hash[field] = value
+		delta += int64(len(field) + len(value))
+	}
+	c.resize(entry, delta, false)
+	return added, nil
+}
+
+func (c *MemoryCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memoryHash(nil), false)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string][]byte{}
+	if entry != nil {
+		for field, value := range entry.data.(memoryHash) {
+			result[field] = cloneBytes(value)
+		}
+	}
+	return result, nil
+}
+
+func (c *MemoryCache) LPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	data := make([][]byte, len(values))
+	for i, value := range values {
+		encoded, err := encodeValue(c.codec, value)
+		if err != nil {
+			return 0, err
+		}
+		data[i] = encoded
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memoryList{}, true)
+	if err != nil {
+		return 0, err
+	}
+	l := entry.data.(memoryList)
+	var delta int64
+	for _, value := range data {
+		l.PushFront(value)
+		delta += int64(len(value))
+	}
+	c.resize(entry, delta, false)
+	if c.pushed != nil {
+		close(c.pushed)
+		c.pushed = nil
+	}
+	return int64(l.Len()), nil
+}
+
+func (c *MemoryCache) RPop(ctx context.Context, key string) (interface{}, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	value, _, err := c.rpop(key)
+	return value, err
+}
+
+// 列表为空时等待写入，超时返回 nil；timeout 为0时等待至 ctx 结束
+func (c *MemoryCache) BRPop(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		if err := c.lock(); err != nil {
+			return nil, err
+		}
+		value, pushed, err := c.rpop(key)
+		c.mu.Unlock()
+		if value != nil || err != nil {
+			return value, err
+		}
+		select {
+		case <-pushed:
+		case <-deadline:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 列表为空时返回用于等待写入的通道
+func (c *MemoryCache) rpop(key string) (interface{}, <-chan struct{}, error) {
+	entry, err := c.structure(key, memoryList{}, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil {
+		if c.pushed == nil {
+			c.pushed = make(chan struct{})
+		}
+		return nil, c.pushed, nil
+	}
+	l := entry.data.(memoryList)
+	value := l.Remove(l.Back()).([]byte)
+	c.resize(entry, -int64(len(value)), l.Len() == 0)
+	return value, nil, nil
+}
+
+func (c *MemoryCache) SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	data := make([][]byte, len(members))
+	for i, member := range members {
+		encoded, err := encodeValue(c.codec, member)
+		if err != nil {
+			return 0, err
+		}
+		data[i] = encoded
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memorySet(nil), true)
+	if err != nil {
+		return 0, err
+	}
+	set := entry.data.(memorySet)
+	var added, delta int64
+	for _, member := range data {
+		if _, ok := set[string(member)]; ok {
+			continue
+		}
+		set[string(member)] = struct{}{}
+		added++
+		delta += int64(len(member))
+	}
+	c.resize(entry, delta, false)
+	return added, nil
+}
+
+func (c *MemoryCache) SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	data, err := encodeValue(c.codec, member)
+	if err != nil {
+		return false, err
+	}
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memorySet(nil), false)
+	if entry == nil {
+		return false, err
+	}
+	_, ok := entry.data.(memorySet)[string(data)]
+	return ok, nil
+}
+
+func (c *MemoryCache) ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	data := make([]string, len(members))
+	for i, member := range members {
+		if math.IsNaN(member.Score) {
+			return 0, redigo.Error("ERR value is not a valid float")
+		}
+		encoded, err := encodeValue(c.codec, member.Member)
+		if err != nil {
+			return 0, err
+		}
+		data[i] = string(encoded)
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memoryZSet(nil), true)
+	if err != nil {
+		return 0, err
+	}
+	zset := entry.data.(memoryZSet)
+	var added, delta int64
+	for i, member := range members {
+		if _, ok := zset[data[i]]; !ok {
+			added++
+			delta += int64(len(data[i]) + 8)
+		}
+		zset[data[i]] = member.Score
+	}
+	c.resize(entry, delta, false)
+	return added, nil
+}
+
+func (c *MemoryCache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]Z, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memoryZSet(nil), false)
+	if entry == nil {
+		return []Z{}, err
+	}
+	result := []Z{}
+	for _, member := range entry.data.(memoryZSet).sorted() {
+		if member.Score >= min && member.Score <= max {
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+// count 小于1时按1处理
+func (c *MemoryCache) ZPopMin(ctx context.Context, key string, count int64) ([]Z, error) {
+	if count < 1 {
+		count = 1
+	}
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	entry, err := c.structure(key, memoryZSet(nil), false)
+	if entry == nil {
+		return []Z{}, err
+	}
+	zset := entry.data.(memoryZSet)
+	members := zset.sorted()
+	if int64(len(members)) > count {
+		members = members[:count]
+	}
+	var delta int64
+	for _, member := range members {
+		delete(zset, member.Member)
+		delta -= int64(len(member.Member) + 8)
+	}
+	c.resize(entry, delta, len(zset) == 0)
+	return members, nil
+}
+
+// 按分数排序，分数相同时按成员排序
+func (z memoryZSet) sorted() []Z {
+	members := make([]Z, 0, len(z))
+	for member, score := range z {
+		members = append(members, Z{Member: member, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score < members[j].Score
+		}
+		return members[i].Member < members[j].Member
+	})
+	return members
+}
+
+// 被包装对象支持数据结构时可直接使用
+func (c *hookedCache) HGet(ctx context.Context, key string, field string) (interface{}, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	reply, err := s.HGet(ctx, key, field)
+	outcome := HIT
+	if reply == nil {
+		outcome = MISS
+	}
+	c.emit(ctx, "hget", []string{key}, start, outcome, err)
+	return reply, err
+}
+
+func (c *hookedCache) HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	n, err := s.HSet(ctx, key, values)
+	c.emit(ctx, "hset", []string{key}, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	values, err := s.HGetAll(ctx, key)
+	c.emit(ctx, "hgetall", []string{key}, start, SUCCESS, err)
+	return values, err
+}
+
+func (c *hookedCache) LPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	n, err := s.LPush(ctx, key, values...)
+	c.emit(ctx, "lpush", []string{key}, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) RPop(ctx context.Context, key string) (interface{}, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	reply, err := s.RPop(ctx, key)
+	c.emit(ctx, "rpop", []string{key}, start, SUCCESS, err)
+	return reply, err
+}
+
+func (c *hookedCache) BRPop(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	reply, err := s.BRPop(ctx, key, timeout)
+	c.emit(ctx, "brpop", []string{key}, start, SUCCESS, err)
+	return reply, err
+}
+
+func (c *hookedCache) SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	n, err := s.SAdd(ctx, key, members...)
+	c.emit(ctx, "sadd", []string{key}, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return false, err
+	}
+	start := time.Now()
+	ok, err := s.SIsMember(ctx, key, member)
+	c.emit(ctx, "sismember", []string{key}, start, SUCCESS, err)
+	return ok, err
+}
+
+func (c *hookedCache) ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	n, err := s.ZAdd(ctx, key, members...)
+	c.emit(ctx, "zadd", []string{key}, start, SUCCESS, err)
+	return n, err
+}
+
+func (c *hookedCache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]Z, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	members, err := s.ZRangeByScore(ctx, key, min, max)
+	c.emit(ctx, "zrangebyscore", []string{key}, start, SUCCESS, err)
+	return members, err
+}
+
+func (c *hookedCache) ZPopMin(ctx context.Context, key string, count int64) ([]Z, error) {
+	s, err := structureOf(c.next)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	members, err := s.ZPopMin(ctx, key, count)
+	c.emit(ctx, "zpopmin", []string{key}, start, SUCCESS, err)
+	return members, err
+}
+
+func HGet(ctx context.Context, key string, field string) (interface{}, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return s.HGet(ctx, key, field)
+}
+
+func HSet(ctx context.Context, key string, values map[string]interface{}) (int64, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return 0, err
+	}
+	return s.HSet(ctx, key, values)
+}
+
+func HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return s.HGetAll(ctx, key)
+}
+
+func LPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return 0, err
+	}
+	return s.LPush(ctx, key, values...)
+}
+
+func RPop(ctx context.Context, key string) (interface{}, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return s.RPop(ctx, key)
+}
+
+func BRPop(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return s.BRPop(ctx, key, timeout)
+}
+
+func SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return 0, err
+	}
+	return s.SAdd(ctx, key, members...)
+}
+
+func SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return false, err
+	}
+	return s.SIsMember(ctx, key, member)
+}
+
+func ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return 0, err
+	}
+	return s.ZAdd(ctx, key, members...)
+}
+
+func ZRangeByScore(ctx context.Context, key string, min, max float64) ([]Z, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return s.ZRangeByScore(ctx, key, min, max)
+}
+
+func ZPopMin(ctx context.Context, key string, count int64) ([]Z, error) {
+	s, err := structureOf(Default())
+	if err != nil {
+		return nil, err
+	}
+	return s.ZPopMin(ctx, key, count)
+}
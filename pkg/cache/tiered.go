@@ -0,0 +1,301 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/redisconn"
+	"github.com/aivencs/magic-box/pkg/trace"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_LOCAL_TTL          = 60 * time.Second
+	DEFAULT_INVALIDATE_CHANNEL = "magic-box:cache:invalidate"
+	DEFAULT_HEALTH_INTERVAL    = 30 * time.Second
+	DEFAULT_RECONNECT_INTERVAL = time.Second
+	MAX_RECONNECT_INTERVAL     = 30 * time.Second
+)
+
+// 结构体
+// 本地内存在前，Redis在后的两级缓存
+type TieredCache struct {
+	Local    *MemoryCache
+	Remote   *RedisCache
+	LocalTTL time.Duration
+	Channel  string
+	id       string
+	cancel   context.CancelFunc
+}
+
+// 失效通知的内容
+type invalidation struct {
+	ID      string   `json:"id"`
+	Keys    []string `json:"keys"`
+	Pattern string   `json:"pattern,omitempty"`
+}
+
+// 创建两级缓存对象，并订阅失效通知
+func NewTieredCache(ctx context.Context, option Option) (Cache, error) {
+	remote, err := NewRedisCache(ctx, option)
+	if err != nil {
+		return nil, err
+	}
+	local, err := NewMemoryCache(ctx, option)
+	if err != nil {
+		return nil, err
+	}
+	if option.LocalTTL <= 0 {
+		option.LocalTTL = DEFAULT_LOCAL_TTL
+	}
+	if len(option.Channel) == 0 {
+		option.Channel = DEFAULT_INVALIDATE_CHANNEL
+	}
+	listenCtx, cancel := context.WithCancel(context.Background())
+	c := &TieredCache{
+		Local:    local.(*MemoryCache),
+		Remote:   remote.(*RedisCache),
+		LocalTTL: option.LocalTTL,
+		Channel:  option.Channel,
+		id:       trace.NewTrace(),
+		cancel:   cancel,
+	}
+	go c.listen(listenCtx)
+	return c, nil
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if value, _ := c.Local.Get(ctx, key); value != nil {
+		return value, nil
+	}
+	value, err := c.Remote.Get(ctx, key)
+	if err != nil || value == nil {
+		return value, err
+	}
+	if data, ok := value.([]byte); ok {
+		c.Local.setWithTTL(key, data, c.LocalTTL)
+	}
+	return value, nil
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	reply, err := c.Remote.Set(ctx, key, value)
+	if err != nil {
+		c.Local.Delete(ctx, key)
+		return reply, err
+	}
+	if data, err := encodeValue(c.Remote.codec, value); err == nil {
+		c.Local.setWithTTL(key, data, c.LocalTTL)
+	}
+	c.publish(ctx, key)
+	return reply, nil
+}
+
+func (c *TieredCache) PoolStats() redisconn.Stats {
+	return c.Remote.PoolStats()
+}
+
+func (c *TieredCache) Health(ctx context.Context) error {
+	return c.Remote.Health(ctx)
+}
+
+func (c *TieredCache) Codec() Codec {
+	return c.Remote.codec
+}
+
+// 停止订阅失效通知后关闭远端，并清空本地缓存
+func (c *TieredCache) Close(ctx context.Context) error {
+	c.cancel()
+	err := c.Remote.Close(ctx)
+	c.Local.Close(ctx)
+	return err
+}
+
+func (c *TieredCache) Overdue(ctx context.Context, key string) (bool, error) {
+	return c.Remote.Overdue(ctx, key)
+}
+
+func (c *TieredCache) SetEx(ctx context.Context, key string, value interface{}, sec int) (interface{}, error) {
+	reply, err := c.Remote.SetEx(ctx, key, value, sec)
+	if err != nil {
+		c.Local.Delete(ctx, key)
+		return reply, err
+	}
+	ttl := time.Duration(sec) * time.Second
+	if ttl > c.LocalTTL {
+		ttl = c.LocalTTL
+	}
+	if data, err := encodeValue(c.Remote.codec, value); err == nil {
+		c.Local.setWithTTL(key, data, ttl)
+	}
+	c.publish(ctx, key)
+	return reply, nil
+}
+
+// 删除键并通知其他实例
+func (c *TieredCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	c.Local.Delete(ctx, keys...)
+	count, err := c.Remote.Delete(ctx, keys...)
+	if err != nil {
+		return count, err
+	}
+	c.publish(ctx, keys...)
+	return count, nil
+}
+
+// 键不存在时写入，成功后通知其他实例
+func (c *TieredCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	ok, err := c.Remote.SetNX(ctx, key, value, ttl)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.invalidate(ctx, key)
+	return true, nil
+}
+
+func (c *TieredCache) Exists(ctx context.Context, key string) (bool, error) {
+	if ok, _ := c.Local.Exists(ctx, key); ok {
+		return true, nil
+	}
+	return c.Remote.Exists(ctx, key)
+}
+
+func (c *TieredCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := c.Remote.Expire(ctx, key, ttl)
+	if err != nil {
+		return ok, err
+	}
+	c.invalidate(ctx, key)
+	return ok, nil
+}
+
+func (c *TieredCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.Remote.TTL(ctx, key)
+}
+
+func (c *TieredCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.IncrBy(ctx, key, 1)
+}
+
+func (c *TieredCache) Decr(ctx context.Context, key string) (int64, error) {
+	return c.IncrBy(ctx, key, -1)
+}
+
+// 计数器只在Redis中累加，不写入本地缓存
+func (c *TieredCache) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	value, err := c.Remote.IncrBy(ctx, key, n)
+	if err != nil {
+		return value, err
+	}
+	c.invalidate(ctx, key)
+	return value, nil
+}
+
+// 批量读取，本地未命中的键从Redis读取
+func (c *TieredCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	values, err := c.Local.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	missing := []string{}
+	for i, value := range values {
+		if value == nil {
+			missing = append(missing, keys[i])
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	remote, err := c.Remote.MGet(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	j := 0
+	for i, value := range values {
+		if value != nil {
+			continue
+		}
+		values[i] = remote[j]
+		if data, ok := remote[j].([]byte); ok {
+			c.Local.setWithTTL(keys[i], data, c.LocalTTL)
+		}
+		j++
+	}
+	return values, nil
+}
+
+func (c *TieredCache) MSet(ctx context.Context, values map[string]interface{}) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	if err := c.Remote.MSet(ctx, values); err != nil {
+		c.Local.Delete(ctx, keys...)
+		return err
+	}
+	c.invalidate(ctx, keys...)
+	return nil
+}
+
+// 移除本地缓存并通知其他实例
+func (c *TieredCache) invalidate(ctx context.Context, keys ...string) {
+	c.Local.Delete(ctx, keys...)
+	c.publish(ctx, keys...)
+}
+
+// 通知其他实例移除本地缓存
+//
+// 通知失败时不影响写入结果，其他实例的本地缓存最迟在 LocalTTL 后失效
+func (c *TieredCache) publish(ctx context.Context, keys ...string) error {
+	return c.send(ctx, invalidation{ID: c.id, Keys: keys})
+}
+
+func (c *TieredCache) send(ctx context.Context, message invalidation) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = c.Remote.do(ctx, "PUBLISH", c.Channel, payload)
+	return err
+}
+
+// 持续订阅失效通知，断开后自动重连
+//
+// 订阅成功与重新订阅时清空本地缓存，避免使用断开期间已失效的数据
+func (c *TieredCache) listen(ctx context.Context) {
+	interval := DEFAULT_RECONNECT_INTERVAL
+	for {
+		sub, err := c.Remote.SubscribeWith(ctx, SubscribeOption{
+			Channels: []string{c.Channel},
+			OnSubscribe: func(ctx context.Context) {
+				c.Local.Flush()
+			},
+		})
+		if err == nil {
+			for message := range sub.Messages() {
+				c.handle(message.Data)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > MAX_RECONNECT_INTERVAL {
+			interval = MAX_RECONNECT_INTERVAL
+		}
+	}
+}
+
+func (c *TieredCache) handle(data []byte) {
+	var message invalidation
+	if err := json.Unmarshal(data, &message); err != nil || message.ID == c.id {
+		return
+	}
+	if len(message.Pattern) > 0 {
+		c.Local.DeleteByPattern(context.Background(), message.Pattern)
+	}
+	c.Local.Delete(context.Background(), message.Keys...)
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+)
+
+// 类型化读取，适用于任意缓存对象
+type TypedCache struct {
+	Cache
+}
+
+func Typed(c Cache) TypedCache {
+	return TypedCache{Cache: c}
+}
+
+func (c TypedCache) GetString(ctx context.Context, key string) (string, error) {
+	var value string
+	err := c.decode(ctx, key, CodecOf(c.Cache), &value)
+	return value, err
+}
+
+func (c TypedCache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := c.decode(ctx, key, CodecOf(c.Cache), &value)
+	return value, err
+}
+
+func (c TypedCache) GetInt(ctx context.Context, key string) (int, error) {
+	var value int
+	err := c.decode(ctx, key, CodecOf(c.Cache), &value)
+	return value, err
+}
+
+func (c TypedCache) GetInt64(ctx context.Context, key string) (int64, error) {
+	var value int64
+	err := c.decode(ctx, key, CodecOf(c.Cache), &value)
+	return value, err
+}
+
+func (c TypedCache) GetFloat64(ctx context.Context, key string) (float64, error) {
+	var value float64
+	err := c.decode(ctx, key, CodecOf(c.Cache), &value)
+	return value, err
+}
+
+// 按json解码到 v
+func (c TypedCache) GetJSON(ctx context.Context, key string, v interface{}) error {
+	return c.decode(ctx, key, JSONCodec{}, v)
+}
+
+// 按缓存对象的序列化方式解码到 v
+func (c TypedCache) GetObject(ctx context.Context, key string, v interface{}) error {
+	return c.decode(ctx, key, CodecOf(c.Cache), v)
+}
+
+func (c TypedCache) decode(ctx context.Context, key string, codec Codec, v interface{}) error {
+	reply, err := c.Cache.Get(ctx, key)
+	return decodeValue(codec, reply, err, v)
+}
+
+func GetString(ctx context.Context, key string) (string, error) {
+	return Typed(Default()).GetString(ctx, key)
+}
+
+func GetBytes(ctx context.Context, key string) ([]byte, error) {
+	return Typed(Default()).GetBytes(ctx, key)
+}
+
+func GetInt(ctx context.Context, key string) (int, error) {
+	return Typed(Default()).GetInt(ctx, key)
+}
+
+func GetInt64(ctx context.Context, key string) (int64, error) {
+	return Typed(Default()).GetInt64(ctx, key)
+}
+
+func GetFloat64(ctx context.Context, key string) (float64, error) {
+	return Typed(Default()).GetFloat64(ctx, key)
+}
+
+func GetJSON(ctx context.Context, key string, v interface{}) error {
+	return Typed(Default()).GetJSON(ctx, key, v)
+}
+
+func GetObject(ctx context.Context, key string, v interface{}) error {
+	return Typed(Default()).GetObject(ctx, key, v)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 	"github.com/spf13/viper"
 	_ "github.com/spf13/viper/remote"
@@ -28,11 +29,14 @@ const (
 )
 
 // 定义全局配置对象
-var conf Conf
-var once sync.Once
+var instances = map[string]Conf{}
+var mu sync.RWMutex
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
 
 func init() {
-	ctx := context.WithValue(context.Background(), "trace", "init-for-config")
+	ctx := trace.WithTrace(context.Background(), "init-for-config")
 	validate.InitValidate(ctx, "validator", validate.Option{})
 }
 
@@ -55,31 +59,55 @@ type Option struct {
 	Interval    int         `json:"interval" label:"即时更新检查间隔" desc:"默认三分钟"`
 }
 
-// 初始化配置对象
+// 初始化默认对象，重复调用时替换默认对象
 func InitConf(ctx context.Context, name SupportType, option Option) error {
-	c := conf
-	var err error
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Conf, error) {
 	message, err := validate.Work(ctx, option)
 	if err != nil {
-		return errors.New(message)
+		return nil, errors.New(message)
 	}
-	once.Do(func() {
-		c = ConfFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		conf = c
-	})
-	return err
+	c, err := ConfFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
+}
+
+// 注册命名对象，名称已存在时替换
+func Register(key string, c Conf) {
+	mu.Lock()
+	defer mu.Unlock()
+	instances[key] = c
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Conf {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Conf {
+	return Use(DEFAULT_INSTANCE)
 }
 
 // 配置的抽象工厂
-func ConfFactory(ctx context.Context, name SupportType, option Option) Conf {
+func ConfFactory(ctx context.Context, name SupportType, option Option) (Conf, error) {
 	switch name {
 	case Consul:
 		return NewConsulConf(ctx, option)
 	default:
-		return NewConsulConf(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %v", name)
 	}
 }
 
@@ -90,7 +118,7 @@ type ConsulConf struct {
 }
 
 // 创建基于Consul的配置对象
-func NewConsulConf(ctx context.Context, option Option) Conf {
+func NewConsulConf(ctx context.Context, option Option) (Conf, error) {
 	// 根据参数调整
 	if utf8.RuneCountInString(option.Host) == 0 {
 		option.Host = DEFAULT_HOST_CONSUL
@@ -106,12 +134,14 @@ func NewConsulConf(ctx context.Context, option Option) Conf {
 	// 获取远端配置并映射到结构体
 	err := vip.ReadRemoteConfig()
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("读取远端配置%s失败: %w", name, err)
 	}
 	if option.Bind != nil {
-		vip.Unmarshal(&option.Bind)
+		if err := vip.Unmarshal(&option.Bind); err != nil {
+			return nil, fmt.Errorf("映射配置%s失败: %w", name, err)
+		}
 	}
-	return &ConsulConf{Kernel: vip}
+	return &ConsulConf{Kernel: vip}, nil
 }
 
 // 定期更新
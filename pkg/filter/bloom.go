@@ -3,10 +3,14 @@ package filter
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	redisbloom "github.com/RedisBloom/redisbloom-go"
+	"github.com/aivencs/magic-box/pkg/lifecycle"
+	"github.com/aivencs/magic-box/pkg/redisconn"
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 	redigo "github.com/gomodule/redigo/redis"
 )
@@ -17,17 +21,20 @@ type SupportType string
 const (
 	BLOOM_FILTER SupportType = "bloom_filter"
 	// 定义默认值
-	DEFAULT_MAXIDLE      = 20
-	DEFAULT_IDLE_TIMEOUT = 120 * time.Second
-	DEFAULT_MAXACTIVE    = 100
+	DEFAULT_MAXIDLE      = redisconn.DEFAULT_MAXIDLE
+	DEFAULT_IDLE_TIMEOUT = redisconn.DEFAULT_IDLE_TIMEOUT
+	DEFAULT_MAXACTIVE    = redisconn.DEFAULT_MAXACTIVE
 )
 
 // 定义全局配置对象
-var filter Filter
-var once sync.Once
+var instances = map[string]Filter{}
+var mu sync.RWMutex
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
 
 func init() {
-	ctx := context.WithValue(context.Background(), "trace", "init-for-filter")
+	ctx := trace.WithTrace(context.Background(), "init-for-filter")
 	validate.InitValidate(ctx, "validator", validate.Option{})
 }
 
@@ -35,48 +42,83 @@ func init() {
 type Filter interface {
 	Exist(ctx context.Context, val string) (bool, error)
 	Add(ctx context.Context, val string) (bool, error)
+	Close(ctx context.Context) error
 }
 
 // 初始化时所用参数
 type Option struct {
-	Host        string        `json:"host" label:"服务地址" validate:"required"`
-	Auth        bool          `json:"auth" label:"是否鉴权" desc:"默认不鉴权"`
-	Username    string        `json:"username" label:"用户名"`
-	Password    string        `json:"password" label:"密码"`
-	Database    string        `json:"database" label:"数据库"`
-	Table       string        `json:"table" label:"数据表"`
-	DB          int           `json:"db" label:"数据库"`
-	MaxIdle     int           `json:"max_idle" label:"最大空闲链接数"`
-	IdleTimeout time.Duration `json:"idle_timeout" label:"空闲超时时间"`
-	MaxActive   int           `json:"max_active" label:"最大链接数"`
-	Key         string        `json:"key" label:"键名"`
-}
-
-// 初始化对象
+	Host             string              `json:"host" label:"服务地址" desc:"未设置服务地址列表时必填"`
+	Auth             bool                `json:"auth" label:"是否鉴权" desc:"默认不鉴权"`
+	Username         string              `json:"username" label:"用户名"`
+	Password         string              `json:"password" label:"密码"`
+	Database         string              `json:"database" label:"数据库"`
+	Table            string              `json:"table" label:"数据表"`
+	DB               int                 `json:"db" label:"数据库"`
+	MaxIdle          int                 `json:"max_idle" label:"最大空闲链接数"`
+	IdleTimeout      time.Duration       `json:"idle_timeout" label:"空闲超时时间"`
+	MaxActive        int                 `json:"max_active" label:"最大链接数"`
+	Key              string              `json:"key" label:"键名"`
+	Mode             redisconn.Mode      `json:"mode" label:"连接方式" desc:"默认为单节点，可选sentinel与cluster"`
+	Addrs            []string            `json:"addrs" label:"服务地址列表" desc:"哨兵或集群使用，为空时使用服务地址"`
+	MasterName       string              `json:"master_name" label:"主节点名称" desc:"哨兵使用"`
+	SentinelPassword string              `json:"sentinel_password" label:"哨兵密码"`
+	TLS              redisconn.TLSOption `json:"tls" label:"TLS参数"`
+	DialTimeout      time.Duration       `json:"dial_timeout" label:"连接超时时间" desc:"为0时不限制"`
+	ReadTimeout      time.Duration       `json:"read_timeout" label:"读取超时时间" desc:"为0时不限制"`
+	WriteTimeout     time.Duration       `json:"write_timeout" label:"写入超时时间" desc:"为0时不限制"`
+}
+
+// 初始化默认对象，重复调用时替换默认对象
 func InitFilter(ctx context.Context, name SupportType, option Option) error {
-	var c = filter
-	var err error
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Filter, error) {
 	message, err := validate.Work(ctx, option)
 	if err != nil {
-		return errors.New(message)
+		return nil, errors.New(message)
 	}
-	once.Do(func() {
-		c = FilterFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		filter = c
-	})
-	return err
+	c, err := FilterFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
+}
+
+// 注册命名对象，名称已存在时替换并关闭原对象，进程退出时由 lifecycle 关闭
+func Register(key string, c Filter) {
+	mu.Lock()
+	instances[key] = c
+	mu.Unlock()
+	// 关闭原对象可能耗时较长，释放锁后再注册，避免阻塞获取对象
+	lifecycle.Register("filter:"+key, c)
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Filter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Filter {
+	return Use(DEFAULT_INSTANCE)
 }
 
 // 抽象工厂
-func FilterFactory(ctx context.Context, name SupportType, option Option) Filter {
+func FilterFactory(ctx context.Context, name SupportType, option Option) (Filter, error) {
 	switch name {
 	case BLOOM_FILTER:
 		return NewBloomFilter(ctx, option)
 	default:
-		return NewBloomFilter(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %s", name)
 	}
 }
 
@@ -89,52 +131,41 @@ type BloomFilter struct {
 }
 
 // 创建基于的对象
-func NewBloomFilter(ctx context.Context, option Option) Filter {
-	applyOption(option)
-	pool := &redigo.Pool{
-		MaxIdle:     option.MaxIdle,
-		IdleTimeout: option.IdleTimeout,
-		MaxActive:   option.MaxActive,
-		Wait:        true, // 连接池无空闲连接时等待
-		Dial: func() (redigo.Conn, error) {
-			c, err := redigo.Dial("tcp", option.Host)
-			if err != nil {
-				return nil, err
-			}
-			if option.Auth {
-				if _, err := c.Do("AUTH", option.Password); err != nil {
-					c.Close()
-					return nil, err
-				}
-				if _, err := c.Do("SELECT", option.DB); err != nil {
-					c.Close()
-					return nil, err
-				}
-			}
-			return c, err
-		},
-		TestOnBorrow: func(c redigo.Conn, t time.Time) error {
-			_, err := c.Do("PING")
-			return err
-		},
+func NewBloomFilter(ctx context.Context, option Option) (Filter, error) {
+	pool, err := redisconn.NewPool(ctx, connOption(option))
+	if err != nil {
+		return nil, err
 	}
 	rbc := redisbloom.NewClientFromPool(pool, option.Key)
 	return &BloomFilter{
 		Kernel: rbc,
 		Pool:   pool,
 		Key:    option.Key,
-	}
+	}, nil
 }
 
-func applyOption(option Option) {
-	if option.MaxIdle == 0 {
-		option.MaxIdle = DEFAULT_MAXIDLE
+// 转换为连接参数，未设置服务地址列表时使用服务地址
+func connOption(option Option) redisconn.Option {
+	addrs := option.Addrs
+	if len(addrs) == 0 && len(option.Host) > 0 {
+		addrs = []string{option.Host}
 	}
-	if option.IdleTimeout == 0 {
-		option.IdleTimeout = DEFAULT_IDLE_TIMEOUT
-	}
-	if option.MaxActive == 0 {
-		option.MaxActive = DEFAULT_MAXACTIVE
+	return redisconn.Option{
+		Mode:             option.Mode,
+		Addrs:            addrs,
+		MasterName:       option.MasterName,
+		SentinelPassword: option.SentinelPassword,
+		Auth:             option.Auth,
+		Username:         option.Username,
+		Password:         option.Password,
+		DB:               option.DB,
+		TLS:              option.TLS,
+		DialTimeout:      option.DialTimeout,
+		ReadTimeout:      option.ReadTimeout,
+		WriteTimeout:     option.WriteTimeout,
+		MaxIdle:          option.MaxIdle,
+		IdleTimeout:      option.IdleTimeout,
+		MaxActive:        option.MaxActive,
 	}
 }
 
@@ -146,10 +177,29 @@ func (c *BloomFilter) Exist(ctx context.Context, val string) (bool, error) {
 	return c.Kernel.Exists(c.Key, val)
 }
 
+// 连接池统计
+func (c *BloomFilter) PoolStats() redisconn.Stats {
+	return redisconn.StatsOf(c.Pool)
+}
+
+// 健康检查
+func (c *BloomFilter) Health(ctx context.Context) error {
+	return redisconn.Health(ctx, c.Pool)
+}
+
+// 关闭连接池
+func (c *BloomFilter) Close(ctx context.Context) error {
+	return redisconn.Close(c.Pool)
+}
+
 func Exist(ctx context.Context, val string) (bool, error) {
-	return filter.Exist(ctx, val)
+	return Default().Exist(ctx, val)
 }
 
 func Add(ctx context.Context, val string) (bool, error) {
-	return filter.Add(ctx, val)
+	return Default().Add(ctx, val)
+}
+
+func Close(ctx context.Context) error {
+	return Default().Close(ctx)
 }
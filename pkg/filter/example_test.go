@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func ExampleBloomFilter() {
-	ctx := context.WithValue(context.Background(), "trace", "ctx-filter-001")
+	ctx := trace.WithTrace(context.Background(), "ctx-filter-001")
 	opt := Option{
 		Host:     "localhost:6379",
 		Auth:     true,
@@ -24,7 +26,7 @@ func ExampleBloomFilter() {
 	}
 	payload := "19619c9e08f0ed4cc147e211efa8c3fb"
 	res, err := Add(ctx, payload)
-	fmt.Println(res, err) // output: false nil
+	fmt.Println(res, err) // 输出: false nil
 	ex, err := Exist(ctx, payload)
-	fmt.Println(ex, err) // output: true nil
+	fmt.Println(ex, err) // 输出: true nil
 }
@@ -0,0 +1,68 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/trace"
+)
+
+func ExampleManager() {
+	ctx := trace.WithTrace(context.Background(), "ctx-lifecycle-001")
+	m := NewManager(Option{Timeout: 5 * time.Second})
+	// 按初始化顺序注册，依赖其他组件的组件后注册
+	for _, name := range []string{"cache:default", "filter:default", "messenger:default"} {
+		name := name
+		m.Register(name, CloserFunc(func(ctx context.Context) error {
+			fmt.Println("关闭", name)
+			if name == "filter:default" {
+				return errors.New("连接已断开")
+			}
+			return nil
+		}))
+	}
+	fmt.Println(m.Names())
+	// 单个组件失败时继续关闭其他组件，返回首个错误
+	fmt.Println(m.Shutdown(ctx))
+	// 重复调用时返回首次关闭的结果
+	fmt.Println(m.Shutdown(ctx))
+	// Output:
+	// [cache:default filter:default messenger:default]
+	// 关闭 messenger:default
+	// 关闭 filter:default
+	// 关闭 cache:default
+	// 关闭filter:default失败: 连接已断开
+	// 关闭filter:default失败: 连接已断开
+}
+
+func ExampleManager_Register() {
+	m := NewManager(Option{})
+	closer := func(name string) Closer {
+		return CloserFunc(func(ctx context.Context) error {
+			fmt.Println("关闭", name)
+			return nil
+		})
+	}
+	m.Register("cache:default", closer("旧对象"))
+	// 名称已存在时替换，被替换的组件立即关闭
+	m.Register("cache:default", closer("新对象"))
+	fmt.Println(m.Names())
+	// Output:
+	// 关闭 旧对象
+	// [cache:default]
+}
+
+func ExampleWait() {
+	ctx := trace.WithTrace(context.Background(), "ctx-lifecycle-002")
+	// 缓存、过滤器、消息与请求对象注册时自动加入默认对象，日志对象最后关闭
+	Register("worker", CloserFunc(func(ctx context.Context) error {
+		// 等待进行中的任务完成，超过关闭超时时间时放弃
+		return nil
+	}))
+	// 阻塞至收到SIGTERM或SIGINT，随后按注册的逆序关闭
+	if err := Wait(ctx); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,253 @@
+// 此包用于在进程退出时按初始化的逆序关闭组件
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+	"github.com/aivencs/magic-box/pkg/validate"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_TIMEOUT = 30 * time.Second
+)
+
+// 默认对象
+var manager = NewManager(Option{})
+
+func init() {
+	ctx := trace.WithTrace(context.Background(), "init-for-lifecycle")
+	validate.InitValidate(ctx, "validator", validate.Option{})
+	// 最先注册，其他组件关闭后再关闭日志对象
+	manager.Register("logger", CloserFunc(logger.Close))
+}
+
+// 可关闭的组件，缓存、过滤器、消息、请求与日志对象均已实现
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// 使用函数作为组件
+type CloserFunc func(ctx context.Context) error
+
+func (f CloserFunc) Close(ctx context.Context) error {
+	return f(ctx)
+}
+
+// 初始化时所用参数
+type Option struct {
+	Timeout time.Duration `json:"timeout" label:"关闭超时时间" desc:"ctx 未设置截止时间时使用，全部组件共用，默认30秒"`
+	Signals []os.Signal   `json:"-" label:"退出信号" desc:"默认为SIGTERM与SIGINT"`
+}
+
+type component struct {
+	name   string
+	closer Closer
+}
+
+// 结构体
+// 按注册顺序记录组件，关闭时逆序执行，先关闭依赖其他组件的组件
+type Manager struct {
+	option     Option
+	components []component
+	closed     bool
+	done       chan struct{}
+	err        error
+	once       sync.Once
+	mu         sync.Mutex
+}
+
+// 初始化默认对象的参数，已注册的组件保持不变
+func InitLifecycle(ctx context.Context, option Option) error {
+	message, err := validate.Work(ctx, option)
+	if err != nil {
+		return errors.New(message)
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.option = applyDefault(option)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func NewManager(option Option) *Manager {
+	return &Manager{
+		option: applyDefault(option),
+		done:   make(chan struct{}),
+	}
+}
+
+// 获取默认对象
+func Default() *Manager {
+	return manager
+}
+
+func applyDefault(option Option) Option {
+	if option.Timeout <= 0 {
+		option.Timeout = DEFAULT_TIMEOUT
+	}
+	if len(option.Signals) == 0 {
+		option.Signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	return option
+}
+
+// 注册组件，名称已存在时替换并保持原有顺序，被替换的组件立即关闭
+//
+// 已关闭后注册的组件立即关闭
+func (m *Manager) Register(name string, c Closer) {
+	m.mu.Lock()
+	timeout := m.option.Timeout
+	if m.closed {
+		m.mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		closeComponent(ctx, component{name: name, closer: c})
+		return
+	}
+	var replaced Closer
+	for i := range m.components {
+		if m.components[i].name == name {
+			replaced, m.components[i].closer = m.components[i].closer, c
+			break
+		}
+	}
+	if replaced == nil {
+		m.components = append(m.components, component{name: name, closer: c})
+	}
+	m.mu.Unlock()
+	// 重复注册同一组件时不关闭
+	if replaced == nil || same(replaced, c) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	closeComponent(ctx, component{name: name, closer: replaced})
+}
+
+// 类型不可比较时视为不同组件
+func same(a, b Closer) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// 移除组件，不会关闭该组件
+func (m *Manager) Unregister(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.components {
+		if m.components[i].name == name {
+			m.components = append(m.components[:i], m.components[i+1:]...)
+			return
+		}
+	}
+}
+
+// 已注册的组件名称，与注册顺序一致
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.components))
+	for _, c := range m.components {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// 按注册的逆序关闭全部组件，单个组件失败时继续关闭其他组件并返回首个错误
+//
+// ctx 未设置截止时间时使用关闭超时时间；重复调用时返回首次关闭的结果
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.once.Do(func() {
+		m.mu.Lock()
+		m.closed = true
+		components := m.components
+		m.components = nil
+		timeout := m.option.Timeout
+		m.mu.Unlock()
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		for i := len(components) - 1; i >= 0; i-- {
+			if err := closeComponent(ctx, components[i]); err != nil && m.err == nil {
+				m.err = err
+			}
+		}
+		close(m.done)
+	})
+	<-m.done
+	return m.err
+}
+
+// 阻塞至收到退出信号或 ctx 结束，随后关闭全部组件
+func (m *Manager) Wait(ctx context.Context) error {
+	m.mu.Lock()
+	signals := m.option.Signals
+	m.mu.Unlock()
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+	select {
+	case <-received:
+	case <-ctx.Done():
+	case <-m.done:
+		return m.err
+	}
+	// ctx 可能已结束，关闭时使用新的 ctx
+	return m.Shutdown(trace.WithTrace(context.Background(), trace.TraceFrom(ctx)))
+}
+
+// 全部组件关闭后关闭
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+// 关闭失败时记录日志，未初始化日志对象时不记录
+func closeComponent(ctx context.Context, c component) error {
+	start := time.Now()
+	err := c.closer.Close(ctx)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("关闭%s失败: %w", c.name, err)
+	if logger.Default() != nil {
+		logger.Error(ctx, logger.Message{
+			Text:  err.Error(),
+			Label: trace.LabelFrom(ctx),
+			Attr: logger.Attr{
+				Monitor: logger.Monitor{ProcessDuration: time.Since(start).Milliseconds()},
+				Inp:     map[string]interface{}{"component": c.name},
+			},
+			Error: err,
+		})
+	}
+	return err
+}
+
+func Register(name string, c Closer) {
+	manager.Register(name, c)
+}
+
+func Unregister(name string) {
+	manager.Unregister(name)
+}
+
+func Shutdown(ctx context.Context) error {
+	return manager.Shutdown(ctx)
+}
+
+func Wait(ctx context.Context) error {
+	return manager.Wait(ctx)
+}
@@ -0,0 +1,93 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/cache"
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func ExampleMemoryLimiter() {
+	ctx := trace.WithTrace(context.Background(), "ctx-limiter-001")
+	// 未设置缓存对象时使用进程内存
+	err := InitLimiter(ctx, FIXED_WINDOW, Option{Limit: 2, Window: time.Minute})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		result, _ := Allow(ctx, "user:1")
+		fmt.Println(result.Allowed, result.Remaining)
+	}
+	// 数量小于1时返回 PVERROR，不会返还计数
+	_, err = AllowN(ctx, "user:1", -1)
+	fmt.Println(logger.CodeOf(err) == logger.PVERROR, err)
+	// Output:
+	// true 1
+	// true 0
+	// false 0
+	// true 数量不能小于1
+}
+
+func ExampleRedisLimiter() {
+	ctx := trace.WithTrace(context.Background(), "ctx-limiter-002")
+	// 使用本地模拟的Redis服务演示
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	c, err := cache.New(ctx, cache.REDIS, cache.Option{Host: server.Addr()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range []SupportType{FIXED_WINDOW, SLIDING_WINDOW, TOKEN_BUCKET} {
+		l, err := New(ctx, name, Option{Limit: 3, Window: time.Minute, Cache: c, DisableFallback: true})
+		if err != nil {
+			log.Fatal(err)
+		}
+		result, _ := l.AllowN(ctx, "api", 3)
+		fmt.Print(name, " ", result.Allowed, " ")
+		result, _ = l.Allow(ctx, "api")
+		fmt.Println(result.Allowed, result.RetryAfter > 0)
+	}
+	// Output:
+	// fixed_window true false true
+	// sliding_window true false true
+	// token_bucket true false true
+}
+
+func ExampleRedisLimiter_timeout() {
+	ctx := trace.WithTrace(context.Background(), "ctx-limiter-003")
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	c, err := cache.New(ctx, cache.REDIS, cache.Option{Host: server.Addr(), MaxActive: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 占用唯一的连接，限流在调用超时时间后返回而不是一直等待
+	conn := c.(*cache.RedisCache).Pool.Get()
+	defer conn.Close()
+	strict, err := New(ctx, FIXED_WINDOW, Option{Limit: 3, Window: time.Minute, Cache: c, DisableFallback: true, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = strict.Allow(ctx, "api")
+	fmt.Println(logger.CodeOf(err) == logger.TIMEOUT)
+	// 默认降级为进程内存限流
+	fallback, err := New(ctx, FIXED_WINDOW, Option{Limit: 3, Window: time.Minute, Cache: c, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(fallback.Allow(ctx, "api"))
+	// Output:
+	// true
+	// {true 3 2 0s} <nil>
+}
@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/cache"
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+	"github.com/aivencs/magic-box/pkg/validate"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// 使用枚举限定选择
+type SupportType string
+
+const (
+	FIXED_WINDOW   SupportType = "fixed_window"   // 固定窗口
+	SLIDING_WINDOW SupportType = "sliding_window" // 滑动窗口
+	TOKEN_BUCKET   SupportType = "token_bucket"   // 令牌桶
+	// 定义默认值
+	DEFAULT_PREFIX  = "magic-box:limiter:"
+	DEFAULT_TIMEOUT = 200 * time.Millisecond
+)
+
+// 定义全局配置对象
+var instances = map[string]Limiter{}
+var mu sync.RWMutex
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
+
+func init() {
+	ctx := trace.WithTrace(context.Background(), "init-for-limiter")
+	validate.InitValidate(ctx, "validator", validate.Option{})
+}
+
+// 抽象接口
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	AllowN(ctx context.Context, key string, n int64) (Result, error)
+}
+
+// 限流结果
+type Result struct {
+	Allowed    bool          `json:"allowed" label:"是否放行"`
+	Limit      int64         `json:"limit" label:"限制数量"`
+	Remaining  int64         `json:"remaining" label:"剩余数量"`
+	RetryAfter time.Duration `json:"retry_after" label:"建议重试间隔" desc:"放行时为0"`
+}
+
+// 初始化时所用参数
+//
+// 窗口类限流表示 Window 内最多 Limit 次；令牌桶表示容量为 Limit，每个 Window 补满一次
+type Option struct {
+	Limit           int64         `json:"limit" label:"限制数量" validate:"required,min=1"`
+	Window          time.Duration `json:"window" label:"时间窗口" validate:"required"`
+	Prefix          string        `json:"prefix" label:"键前缀" desc:"默认为magic-box:limiter:"`
+	Cache           cache.Cache   `json:"-" label:"缓存对象" desc:"为Redis或两级缓存时在多个实例间共享，否则使用进程内存"`
+	DisableFallback bool          `json:"disable_fallback" label:"关闭降级" desc:"默认Redis出错时降级为进程内存限流"`
+	Timeout         time.Duration `json:"timeout" label:"调用超时时间" desc:"Redis使用，包含等待空闲连接的时间，默认200毫秒"`
+}
+
+// 初始化默认对象，重复调用时替换默认对象
+func InitLimiter(ctx context.Context, name SupportType, option Option) error {
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Limiter, error) {
+	message, err := validate.Work(ctx, option)
+	if err != nil {
+		return nil, errors.New(message)
+	}
+	c, err := LimiterFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
+}
+
+// 注册命名对象，名称已存在时替换
+func Register(key string, c Limiter) {
+	mu.Lock()
+	defer mu.Unlock()
+	instances[key] = c
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Limiter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Limiter {
+	return Use(DEFAULT_INSTANCE)
+}
+
+// 抽象工厂，缓存对象基于Redis时使用Redis，否则使用进程内存
+func LimiterFactory(ctx context.Context, name SupportType, option Option) (Limiter, error) {
+	switch name {
+	case FIXED_WINDOW, SLIDING_WINDOW, TOKEN_BUCKET:
+	default:
+		return nil, fmt.Errorf("不支持的类型: %s", name)
+	}
+	if option.Window < time.Millisecond {
+		return nil, errors.New("时间窗口不能小于1毫秒")
+	}
+	if len(option.Prefix) == 0 {
+		option.Prefix = DEFAULT_PREFIX
+	}
+	if pool := poolOf(option.Cache); pool != nil {
+		return NewRedisLimiter(ctx, name, option, pool)
+	}
+	return NewMemoryLimiter(ctx, name, option)
+}
+
+// 数量小于1时会返还令牌或计数，返回 PVERROR
+func checkN(n int64) error {
+	if n < 1 {
+		return logger.NewError(logger.PVERROR, "数量不能小于1")
+	}
+	return nil
+}
+
+func poolOf(c cache.Cache) *redigo.Pool {
+	switch c := cache.Unwrap(c).(type) {
+	case *cache.RedisCache:
+		return c.Pool
+	case *cache.TieredCache:
+		return c.Remote.Pool
+	default:
+		return nil
+	}
+}
+
+func Allow(ctx context.Context, key string) (Result, error) {
+	return Default().Allow(ctx, key)
+}
+
+func AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	return Default().AllowN(ctx, key, n)
+}
@@ -0,0 +1,136 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// 结构体
+// 基于进程内存，仅在当前实例内生效
+type MemoryLimiter struct {
+	kind   SupportType
+	option Option
+	states map[string]*memoryState
+	swept  time.Time
+	mu     sync.Mutex
+}
+
+type memoryState struct {
+	count    int64       // 固定窗口内的次数
+	stamps   []time.Time // 滑动窗口内的请求时间
+	tokens   float64     // 令牌桶剩余令牌
+	updated  time.Time
+	expireAt time.Time
+}
+
+// 创建基于内存的对象
+func NewMemoryLimiter(ctx context.Context, name SupportType, option Option) (Limiter, error) {
+	return &MemoryLimiter{
+		kind:   name,
+		option: option,
+		states: map[string]*memoryState{},
+		swept:  time.Now(),
+	}, nil
+}
+
+func (c *MemoryLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return c.AllowN(ctx, key, 1)
+}
+
+func (c *MemoryLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkN(n); err != nil {
+		return Result{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.sweep(now)
+	state, ok := c.states[key]
+	if !ok || !now.Before(state.expireAt) {
+		state = &memoryState{tokens: float64(c.option.Limit), updated: now}
+		c.states[key] = state
+	}
+	switch c.kind {
+	case SLIDING_WINDOW:
+		return c.sliding(state, now, n), nil
+	case TOKEN_BUCKET:
+		return c.bucket(state, now, n), nil
+	default:
+		return c.fixed(state, now, n), nil
+	}
+}
+
+func (c *MemoryLimiter) fixed(state *memoryState, now time.Time, n int64) Result {
+	if state.count == 0 {
+		state.expireAt = now.Add(c.option.Window)
+	}
+	result := Result{Limit: c.option.Limit}
+	if state.count+n > c.option.Limit {
+		result.Remaining = c.option.Limit - state.count
+		result.RetryAfter = state.expireAt.Sub(now)
+		return result
+	}
+	state.count += n
+	result.Allowed = true
+	result.Remaining = c.option.Limit - state.count
+	return result
+}
+
+func (c *MemoryLimiter) sliding(state *memoryState, now time.Time, n int64) Result {
+	start := now.Add(-c.option.Window)
+	i := 0
+	for i < len(state.stamps) && !state.stamps[i].After(start) {
+		i++
+	}
+	state.stamps = state.stamps[i:]
+	count := int64(len(state.stamps))
+	result := Result{Limit: c.option.Limit, Remaining: c.option.Limit - count}
+	if count+n > c.option.Limit {
+		// 需要等待最早的若干次请求移出窗口
+		if index := count + n - c.option.Limit - 1; index < count {
+			result.RetryAfter = state.stamps[index].Add(c.option.Window).Sub(now)
+		} else {
+			result.RetryAfter = c.option.Window
+		}
+		return result
+	}
+	for j := int64(0); j < n; j++ {
+		state.stamps = append(state.stamps, now)
+	}
+	state.expireAt = now.Add(c.option.Window)
+	result.Allowed = true
+	result.Remaining -= n
+	return result
+}
+
+func (c *MemoryLimiter) bucket(state *memoryState, now time.Time, n int64) Result {
+	capacity := float64(c.option.Limit)
+	rate := capacity / float64(c.option.Window)
+	state.tokens = math.Min(capacity, state.tokens+float64(now.Sub(state.updated))*rate)
+	state.updated = now
+	state.expireAt = now.Add(c.option.Window)
+	result := Result{Limit: c.option.Limit}
+	if state.tokens >= float64(n) {
+		state.tokens -= float64(n)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((float64(n) - state.tokens) / rate))
+	}
+	result.Remaining = int64(state.tokens)
+	return result
+}
+
+// 每个窗口清理一次已过期的键
+func (c *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(c.swept) < c.option.Window {
+		return
+	}
+	c.swept = now
+	for key, state := range c.states {
+		if !now.Before(state.expireAt) {
+			delete(c.states, key)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// 固定窗口，首次计数时设置窗口有效期
+var fixedScript = redigo.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+if current + n > limit then
+	return {0, limit - current, redis.call("PTTL", KEYS[1])}
+end
+current = redis.call("INCRBY", KEYS[1], n)
+if current == n then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return {1, limit - current, 0}`)
+
+// 滑动窗口，使用有序集合记录窗口内的请求时间
+var slidingScript = redigo.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count + n > limit then
+	local retry = window
+	local index = count + n - limit - 1
+	if index < count then
+		local oldest = redis.call("ZRANGE", KEYS[1], index, index, "WITHSCORES")
+		retry = tonumber(oldest[2]) + window - now
+	end
+	return {0, limit - count, retry}
+end
+for i = 1, n do
+	redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, limit - count - n, 0}`)
+
+// 令牌桶，按流逝时间补充令牌
+var bucketScript = redigo.NewScript(1, `
+local capacity = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local rate = capacity / window
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or capacity
+local updated = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+else
+	retry = math.ceil((n - tokens) / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], window)
+return {allowed, math.floor(tokens), retry}`)
+
+// 结构体
+// 基于Redis的Lua脚本，多个实例共享计数
+type RedisLimiter struct {
+	Pool   *redigo.Pool
+	kind   SupportType
+	option Option
+	script *redigo.Script
+	local  Limiter
+}
+
+// 创建基于Redis的对象
+func NewRedisLimiter(ctx context.Context, name SupportType, option Option, pool *redigo.Pool) (Limiter, error) {
+	local, err := NewMemoryLimiter(ctx, name, option)
+	if err != nil {
+		return nil, err
+	}
+	if option.Timeout <= 0 {
+		option.Timeout = DEFAULT_TIMEOUT
+	}
+	c := &RedisLimiter{
+		Pool:   pool,
+		kind:   name,
+		option: option,
+		local:  local,
+	}
+	switch name {
+	case SLIDING_WINDOW:
+		c.script = slidingScript
+	case TOKEN_BUCKET:
+		c.script = bucketScript
+	default:
+		c.script = fixedScript
+	}
+	return c, nil
+}
+
+func (c *RedisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return c.AllowN(ctx, key, 1)
+}
+
+// Redis出错时降级为进程内存限流，关闭降级时返回错误
+func (c *RedisLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkN(n); err != nil {
+		return Result{}, err
+	}
+	result, err := c.eval(ctx, key, n)
+	if err != nil && !c.option.DisableFallback {
+		return c.local.AllowN(ctx, key, n)
+	}
+	return result, err
+}
+
+// 等待空闲连接超时返回 TIMEOUT，执行超时返回 CALLTIMEOUT，与缓存一致
+func (c *RedisLimiter) eval(ctx context.Context, key string, n int64) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.option.Timeout)
+	defer cancel()
+	r, err := c.Pool.GetContext(ctx)
+	if err != nil {
+		return Result{}, logger.WrapTimeout(err, logger.TIMEOUT)
+	}
+	defer r.Close()
+	window := c.option.Window.Milliseconds()
+	// 不同限流方式的数据结构不同，键名中附带限流方式
+	args := redigo.Args{c.option.Prefix + string(c.kind) + ":" + key, c.option.Limit, n}
+	switch c.kind {
+	case SLIDING_WINDOW:
+		args = args.Add(window, time.Now().UnixNano()/int64(time.Millisecond), trace.NewTrace())
+	case TOKEN_BUCKET:
+		args = args.Add(window, time.Now().UnixNano()/int64(time.Millisecond))
+	default:
+		args = args.Add(window)
+	}
+	values, err := redigo.Int64s(c.script.DoContext(ctx, r, args...))
+	if err != nil {
+		return Result{}, logger.WrapTimeout(err, logger.CALLTIMEOUT)
+	}
+	result := Result{
+		Allowed:   values[0] == 1,
+		Limit:     c.option.Limit,
+		Remaining: values[1],
+	}
+	if values[2] > 0 {
+		result.RetryAfter = time.Duration(values[2]) * time.Millisecond
+	}
+	return result, nil
+}
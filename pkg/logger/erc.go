@@ -1,17 +1,40 @@
 package logger
 
-import "unicode/utf8"
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
-var erc map[MessageCode]ErrorCode
+	"gopkg.in/yaml.v2"
+)
+
+var registry = NewRegistry()
+var registryMu sync.RWMutex
 var defaultErrorCode = SUCCESS
 
 type MessageCode uint
 
+// 使用枚举限定选择
+type Language string
+
 type ErrorCode struct {
-	Name  string
-	Code  MessageCode
-	Level LoggerLevel
-	Label string
+	Name   string              `json:"name" yaml:"name"`
+	Code   MessageCode         `json:"code" yaml:"code"`
+	Level  LoggerLevel         `json:"level" yaml:"level"`
+	Label  string              `json:"label" yaml:"label"`
+	Labels map[Language]string `json:"labels" yaml:"labels"`
+}
+
+// 信息码区间，用于划分不同应用可使用的信息码
+type CodeRange struct {
+	Name string      `json:"name" yaml:"name"`
+	Min  MessageCode `json:"min" yaml:"min"`
+	Max  MessageCode `json:"max" yaml:"max"`
 }
 
 const (
@@ -30,37 +53,297 @@ const (
 	CALLTIMEOUT MessageCode = 20001 // 调用超时 error
 	CALLERROR   MessageCode = 20002 // 调用错误 error
 	INTERRUPT   MessageCode = 30001 // 组件中断 fatal
+	UNKNOWN     MessageCode = 99999 // 未知信息码 error
 )
 
-func InitErrorCode() {
-	erc = map[MessageCode]ErrorCode{
-		SUCCESS:     {Code: SUCCESS, Level: INFO, Label: "操作成功"},
-		CHECK:       {Code: CHECK, Level: WARN, Label: "请检查"},
-		LIMITERROR:  {Code: LIMITERROR, Level: ERROR, Label: "超限"},
-		TIMEOUT:     {Code: TIMEOUT, Level: ERROR, Label: "超时"},
-		SUPWARN:     {Code: SUPWARN, Level: WARN, Label: "补充数据"},
-		STATUSERROR: {Code: STATUSERROR, Level: ERROR, Label: "非常规状态码"},
-		EDERROR:     {Code: EDERROR, Level: ERROR, Label: "编码或解码错误"},
-		RPERROR:     {Code: RPERROR, Level: ERROR, Label: "运行时参数错误"},
-		PVERROR:     {Code: PVERROR, Level: ERROR, Label: "参数未通过校验"},
-		DVERROR:     {Code: DVERROR, Level: ERROR, Label: "数据结果未通过校验"},
-		RWARN:       {Code: RWARN, Level: WARN, Label: "运行时发生异常"},
-		RPWARN:      {Code: RPWARN, Level: WARN, Label: "运行时发生错误"},
-		CALLTIMEOUT: {Code: CALLTIMEOUT, Level: ERROR, Label: "调用超时"},
-		CALLERROR:   {Code: CALLERROR, Level: ERROR, Label: "调用错误"},
-		INTERRUPT:   {Code: INTERRUPT, Level: FATAL, Label: "组件中断"},
+const (
+	// Language
+	ZH Language = "zh" // 中文
+	EN Language = "en" // 英文
+	// 定义默认值
+	DEFAULT_LANGUAGE = ZH
+	// 内置信息码占用的区间
+	BUILTIN_RANGE_NAME                   = "builtin"
+	BUILTIN_RANGE_MIN        MessageCode = 10000
+	BUILTIN_RANGE_MAX        MessageCode = 39999
+	UNKNOWN_RANGE_NAME                   = "unknown"
+	DEFAULT_UNKNOWN_NAME                 = "UNKNOWN"
+	DEFAULT_UNKNOWN_LABEL                = "未知信息码"
+	DEFAULT_UNKNOWN_LABEL_EN             = "unknown message code"
+)
+
+// 内置信息码
+func builtinErrorCode() []ErrorCode {
+	return []ErrorCode{
+		{Name: "SUCCESS", Code: SUCCESS, Level: INFO, Label: "操作成功", Labels: map[Language]string{EN: "success"}},
+		{Name: "CHECK", Code: CHECK, Level: WARN, Label: "请检查", Labels: map[Language]string{EN: "please check"}},
+		{Name: "LIMITERROR", Code: LIMITERROR, Level: ERROR, Label: "超限", Labels: map[Language]string{EN: "limit exceeded"}},
+		{Name: "TIMEOUT", Code: TIMEOUT, Level: ERROR, Label: "超时", Labels: map[Language]string{EN: "timeout"}},
+		{Name: "SUPWARN", Code: SUPWARN, Level: WARN, Label: "补充数据", Labels: map[Language]string{EN: "supplementary data"}},
+		{Name: "STATUSERROR", Code: STATUSERROR, Level: ERROR, Label: "非常规状态码", Labels: map[Language]string{EN: "unexpected status code"}},
+		{Name: "EDERROR", Code: EDERROR, Level: ERROR, Label: "编码或解码错误", Labels: map[Language]string{EN: "encode or decode error"}},
+		{Name: "RPERROR", Code: RPERROR, Level: ERROR, Label: "运行时参数错误", Labels: map[Language]string{EN: "runtime parameter error"}},
+		{Name: "PVERROR", Code: PVERROR, Level: ERROR, Label: "参数未通过校验", Labels: map[Language]string{EN: "parameter validation failed"}},
+		{Name: "DVERROR", Code: DVERROR, Level: ERROR, Label: "数据结果未通过校验", Labels: map[Language]string{EN: "data validation failed"}},
+		{Name: "RWARN", Code: RWARN, Level: WARN, Label: "运行时发生异常", Labels: map[Language]string{EN: "runtime exception"}},
+		{Name: "RPWARN", Code: RPWARN, Level: WARN, Label: "运行时发生错误", Labels: map[Language]string{EN: "runtime error"}},
+		{Name: "CALLTIMEOUT", Code: CALLTIMEOUT, Level: ERROR, Label: "调用超时", Labels: map[Language]string{EN: "call timeout"}},
+		{Name: "CALLERROR", Code: CALLERROR, Level: ERROR, Label: "调用错误", Labels: map[Language]string{EN: "call error"}},
+		{Name: "INTERRUPT", Code: INTERRUPT, Level: FATAL, Label: "组件中断", Labels: map[Language]string{EN: "component interrupted"}},
+		{Name: DEFAULT_UNKNOWN_NAME, Code: UNKNOWN, Level: ERROR, Label: DEFAULT_UNKNOWN_LABEL, Labels: map[Language]string{EN: DEFAULT_UNKNOWN_LABEL_EN}},
 	}
 }
 
-func GetDefaultErc() ErrorCode {
-	value := erc[defaultErrorCode]
-	return value
+// 信息码注册表
+type Registry struct {
+	codes    map[MessageCode]ErrorCode
+	ranges   []CodeRange
+	language Language
+	mu       sync.RWMutex
 }
 
-func GetErc(code MessageCode, label string) ErrorCode {
-	value := erc[code]
+// 信息码文件的内容格式
+type registryFile struct {
+	Ranges []CodeRange `json:"ranges" yaml:"ranges"`
+	Codes  []ErrorCode `json:"codes" yaml:"codes"`
+}
+
+// 创建包含内置信息码的注册表
+func NewRegistry() *Registry {
+	r := &Registry{
+		codes:    map[MessageCode]ErrorCode{},
+		language: DEFAULT_LANGUAGE,
+		ranges: []CodeRange{
+			{Name: BUILTIN_RANGE_NAME, Min: BUILTIN_RANGE_MIN, Max: BUILTIN_RANGE_MAX},
+			{Name: UNKNOWN_RANGE_NAME, Min: UNKNOWN, Max: UNKNOWN},
+		},
+	}
+	for _, value := range builtinErrorCode() {
+		r.codes[value.Code] = value
+	}
+	return r
+}
+
+// 注册信息码区间，与已有区间重叠时返回错误
+func (r *Registry) RegisterRange(name string, min, max MessageCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value := CodeRange{Name: name, Min: min, Max: max}
+	if err := checkRange(r.ranges, value); err != nil {
+		return err
+	}
+	r.ranges = append(r.ranges, value)
+	return nil
+}
+
+func checkRange(ranges []CodeRange, value CodeRange) error {
+	if value.Min > value.Max {
+		return fmt.Errorf("信息码区间[%d, %d]无效", value.Min, value.Max)
+	}
+	for _, item := range ranges {
+		if item.Name == value.Name {
+			return fmt.Errorf("信息码区间%s已存在", value.Name)
+		}
+		if value.Min <= item.Max && item.Min <= value.Max {
+			return fmt.Errorf("信息码区间%s[%d, %d]与%s[%d, %d]重叠", value.Name, value.Min, value.Max, item.Name, item.Min, item.Max)
+		}
+	}
+	return nil
+}
+
+// 注册信息码，信息码已存在或位于内置区间时返回错误
+func (r *Registry) Register(codes ...ErrorCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.register("", codes)
+}
+
+// 在指定区间内注册信息码，信息码超出区间时返回错误
+func (r *Registry) RegisterIn(name string, codes ...ErrorCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.register(name, codes)
+}
+
+func (r *Registry) register(name string, codes []ErrorCode) error {
+	if err := r.check(r.ranges, name, codes); err != nil {
+		return err
+	}
+	r.add(codes)
+	return nil
+}
+
+// 先整体检查，避免部分注册
+func (r *Registry) check(ranges []CodeRange, name string, codes []ErrorCode) error {
+	seen := map[MessageCode]bool{}
+	for _, value := range codes {
+		if _, ok := r.codes[value.Code]; ok || seen[value.Code] {
+			return fmt.Errorf("信息码%d已存在", value.Code)
+		}
+		owner := ownerOf(ranges, value.Code)
+		if owner == BUILTIN_RANGE_NAME || owner == UNKNOWN_RANGE_NAME {
+			return fmt.Errorf("信息码%d位于保留区间%s", value.Code, owner)
+		}
+		if len(name) > 0 && owner != name {
+			return fmt.Errorf("信息码%d不在区间%s内", value.Code, name)
+		}
+		seen[value.Code] = true
+	}
+	return nil
+}
+
+func (r *Registry) add(codes []ErrorCode) {
+	for _, value := range codes {
+		if value.Level == "" {
+			value.Level = DEFAULT_LEVEL
+		}
+		r.codes[value.Code] = value
+	}
+}
+
+// 信息码所在区间的名称
+func ownerOf(ranges []CodeRange, code MessageCode) string {
+	for _, value := range ranges {
+		if code >= value.Min && code <= value.Max {
+			return value.Name
+		}
+	}
+	return ""
+}
+
+// 从文件加载信息码区间与信息码，支持 yaml 与 json
+//
+// 全部区间与信息码通过检查后才写入，出错时注册表保持不变
+func (r *Registry) Load(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var payload registryFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &payload)
+	case ".json":
+		err = json.Unmarshal(content, &payload)
+	default:
+		return fmt.Errorf("不支持的信息码文件格式: %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ranges := append([]CodeRange{}, r.ranges...)
+	for _, value := range payload.Ranges {
+		if err := checkRange(ranges, value); err != nil {
+			return err
+		}
+		ranges = append(ranges, value)
+	}
+	if err := r.check(ranges, "", payload.Codes); err != nil {
+		return err
+	}
+	r.ranges = ranges
+	r.add(payload.Codes)
+	return nil
+}
+
+// 设置描述文字所用的语言
+func (r *Registry) SetLanguage(language Language) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.language = language
+}
+
+// 获取信息码，未注册时返回 UNKNOWN
+func (r *Registry) Get(code MessageCode, label string) ErrorCode {
+	r.mu.RLock()
+	language := r.language
+	r.mu.RUnlock()
+	return r.GetIn(code, language, label)
+}
+
+// 获取指定语言的信息码，label 不为空时替换描述文字
+func (r *Registry) GetIn(code MessageCode, language Language, label string) ErrorCode {
+	r.mu.RLock()
+	value, ok := r.codes[code]
+	if !ok {
+		value = r.codes[UNKNOWN]
+	}
+	r.mu.RUnlock()
+	if text, ok := value.Labels[language]; ok && len(text) > 0 {
+		value.Label = text
+	}
 	if utf8.RuneCountInString(label) > 1 {
 		value.Label = label
 	}
+	value.Labels = nil
 	return value
 }
+
+// 已注册的全部信息码
+func (r *Registry) Codes() []ErrorCode {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]ErrorCode, 0, len(r.codes))
+	for _, value := range r.codes {
+		result = append(result, value)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	return result
+}
+
+// 确保内置信息码已注册，可重复调用
+func InitErrorCode() {
+	r := GetRegistry()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, value := range builtinErrorCode() {
+		r.codes[value.Code] = value
+	}
+}
+
+// 使用自定义的注册表
+func SetRegistry(r *Registry) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = r
+}
+
+func GetRegistry() *Registry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry
+}
+
+func RegisterRange(name string, min, max MessageCode) error {
+	return GetRegistry().RegisterRange(name, min, max)
+}
+
+func RegisterErrorCode(codes ...ErrorCode) error {
+	return GetRegistry().Register(codes...)
+}
+
+func RegisterErrorCodeIn(name string, codes ...ErrorCode) error {
+	return GetRegistry().RegisterIn(name, codes...)
+}
+
+func LoadErrorCode(path string) error {
+	return GetRegistry().Load(path)
+}
+
+func SetLanguage(language Language) {
+	GetRegistry().SetLanguage(language)
+}
+
+func GetDefaultErc() ErrorCode {
+	return GetRegistry().Get(defaultErrorCode, "")
+}
+
+func GetErc(code MessageCode, label string) ErrorCode {
+	return GetRegistry().Get(code, label)
+}
+
+func GetErcIn(code MessageCode, language Language, label string) ErrorCode {
+	return GetRegistry().GetIn(code, language, label)
+}
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+)
+
+const DEFAULT_STACK_DEPTH = 32
+
+// 携带信息码的错误
+type CodeError struct {
+	Code  MessageCode `json:"code" label:"信息码"`
+	Level LoggerLevel `json:"level" label:"日志级别"`
+	Label string      `json:"label" label:"描述文字"`
+	Cause error       `json:"-" label:"原始错误"`
+	Stack string      `json:"-" label:"错误栈信息"`
+}
+
+// 根据信息码创建错误，label 为空时使用信息码的默认描述
+func NewError(code MessageCode, label string) *CodeError {
+	return newError(nil, code, label)
+}
+
+// 使用信息码包装已有错误
+func WrapError(cause error, code MessageCode, label string) *CodeError {
+	return newError(cause, code, label)
+}
+
+// 截止时间已到与网络超时使用信息码包装，其他错误原样返回
+func WrapTimeout(err error, code MessageCode) error {
+	var e net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &e) && e.Timeout()) {
+		return newError(err, code, "")
+	}
+	return err
+}
+
+func newError(cause error, code MessageCode, label string) *CodeError {
+	erc := GetErc(code, label)
+	return &CodeError{
+		Code:  code,
+		Level: erc.Level,
+		Label: erc.Label,
+		Cause: cause,
+		Stack: callers(4),
+	}
+}
+
+func (e *CodeError) Error() string {
+	if e.Cause == nil {
+		return e.Label
+	}
+	return fmt.Sprintf("%s: %s", e.Label, e.Cause.Error())
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.Cause
+}
+
+// 信息码相同即视为同一错误
+func (e *CodeError) Is(target error) bool {
+	t, ok := target.(*CodeError)
+	if !ok {
+		return false
+	}
+	return t.Code == e.Code
+}
+
+func (e *CodeError) ErrorCode() ErrorCode {
+	erc := GetErc(e.Code, e.Label)
+	erc.Level = e.Level
+	return erc
+}
+
+// 错误栈信息，包含原始错误
+func (e *CodeError) Traceback() string {
+	if e.Cause == nil {
+		return e.Stack
+	}
+	return fmt.Sprintf("%s\n%s", e.Cause.Error(), e.Stack)
+}
+
+// 获取错误的信息码，nil 为操作成功，非 CodeError 类型视为运行时错误
+func CodeOf(err error) MessageCode {
+	if err == nil {
+		return SUCCESS
+	}
+	var e *CodeError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return RPWARN
+}
+
+// 判断错误链中是否存在指定信息码
+func IsCode(err error, code MessageCode) bool {
+	return errors.Is(err, &CodeError{Code: code})
+}
+
+// 获取错误对应的 ErrorCode
+func ErcOf(err error) ErrorCode {
+	if err == nil {
+		return GetDefaultErc()
+	}
+	var e *CodeError
+	if errors.As(err, &e) {
+		return e.ErrorCode()
+	}
+	return GetErc(RPWARN, err.Error())
+}
+
+func callers(skip int) string {
+	pcs := make([]uintptr, DEFAULT_STACK_DEPTH)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var buffer strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&buffer, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return buffer.String()
+}
@@ -1,12 +1,26 @@
 package logger
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func ExampleZapLogger() {
-	ctx := context.WithValue(context.Background(), "trace", "t00")
+	ctx := trace.WithTrace(context.Background(), "t00")
 	// 初始化日志对象
 	err := InitLogger(ctx, Zap, Option{
 		Application: "zap-log",
@@ -17,8 +31,8 @@ func ExampleZapLogger() {
 		log.Fatal(err)
 	}
 	// 1
-	ctx = context.WithValue(context.Background(), "trace", "t01")
-	logger.Info(ctx, Message{Text: "操作失败", Remark: "标题替代正文", Traceback: "按规则未找到正文",
+	ctx = trace.WithTrace(context.Background(), "t01")
+	Info(ctx, Message{Text: "操作失败", Remark: "标题替代正文", Traceback: "按规则未找到正文",
 		Attr: Attr{
 			Inp: map[string]interface{}{"link": "http://localhost:9087"},
 			Oup: map[string]interface{}{"res": "title"},
@@ -32,8 +46,8 @@ func ExampleZapLogger() {
 		},
 	})
 	// 2
-	ctx = context.WithValue(context.Background(), "trace", "t02")
-	logger.Error(ctx, Message{Text: "work", Remark: "说明", Traceback: "调用超时", Label: "render",
+	ctx = trace.WithTrace(context.Background(), "t02")
+	Error(ctx, Message{Text: "work", Remark: "说明", Traceback: "调用超时", Label: "render",
 		Attr: Attr{
 			Inp: map[string]interface{}{"application": "spanic-service-net"},
 			Oup: map[string]interface{}{"result": ""},
@@ -46,3 +60,260 @@ func ExampleZapLogger() {
 		},
 	})
 }
+
+func ExampleZapLogger_file() {
+	ctx := trace.WithTrace(context.Background(), "t03")
+	// 同时输出到标准输出与文件，文件超过100MB或跨天时切分
+	logger, err := NewZapLogger(ctx, Option{
+		Application: "zap-log",
+		Env:         "dev",
+		Label:       "detail",
+		Encode:      Json,
+		Writers:     []WriterType{Stdout, File},
+		File: FileOption{
+			Path:       "./logs/zap-log.log",
+			MaxSize:    100,
+			Daily:      true,
+			MaxBackups: 7,
+			Compress:   true,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger.Info(ctx, Message{Text: "写入文件"})
+}
+
+func ExampleSetLevel() {
+	ctx := trace.WithTrace(context.Background(), "t04")
+	err := InitLogger(ctx, Zap, Option{
+		Application: "zap-log",
+		Env:         "dev",
+		Label:       "detail",
+		Level:       INFO,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	Debug(ctx, Message{Text: "不会输出"})
+	// 运行时调整日志级别，也可通过 LevelHandler 提供的接口调整
+	SetLevel(DEBUG)
+	Debug(ctx, Message{Text: "调整后输出"})
+}
+
+func ExampleLevelHandler() {
+	ctx := trace.WithTrace(context.Background(), "t05")
+	err := InitLogger(ctx, Zap, Option{Application: "zap-log", Env: "dev", Label: "detail", Level: INFO})
+	if err != nil {
+		log.Fatal(err)
+	}
+	handler := LevelHandler()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(`{"level": "warn"}`)))
+	fmt.Print(w.Code, " ", w.Body.String())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/level", nil))
+	fmt.Print(w.Code, " ", w.Body.String())
+	// Output:
+	// 200 {"level":"warn"}
+	// 200 {"level":"warn"}
+}
+
+func ExampleWrapError() {
+	InitErrorCode()
+	cause := errors.New("dial tcp: i/o timeout")
+	err := fmt.Errorf("获取详情失败: %w", WrapError(cause, TIMEOUT, ""))
+	fmt.Println(IsCode(err, TIMEOUT), errors.Is(err, NewError(TIMEOUT, "")), CodeOf(err))
+	fmt.Println(errors.Is(err, cause))
+	// Output:
+	// true true 10003
+	// true
+}
+
+func ExampleRegistry() {
+	registry := NewRegistry()
+	// 为应用划分信息码区间，并注册应用自定义的信息码
+	err := registry.RegisterRange("spider", 40000, 40999)
+	fmt.Println(err)
+	err = registry.RegisterIn("spider", ErrorCode{
+		Name:   "PARSEERROR",
+		Code:   40001,
+		Level:  ERROR,
+		Label:  "解析失败",
+		Labels: map[Language]string{EN: "parse failed"},
+	})
+	fmt.Println(err)
+	fmt.Println(registry.RegisterRange("crawler", 40500, 41000))
+	fmt.Println(registry.Get(40001, "").Label, registry.GetIn(40001, EN, "").Label)
+	fmt.Println(registry.Get(50001, "").Code)
+	// Output:
+	// <nil>
+	// <nil>
+	// 信息码区间crawler[40500, 41000]与spider[40000, 40999]重叠
+	// 解析失败 parse failed
+	// 99999
+}
+
+func ExampleRegistry_Load() {
+	dir, err := os.MkdirTemp("", "erc")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "codes.yaml")
+	content := `
+ranges:
+  - {name: spider, min: 40000, max: 40999}
+codes:
+  - {name: PARSEERROR, code: 40001, label: 解析失败}
+  - {name: RETRYERROR, code: 10001, label: 重试失败}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		log.Fatal(err)
+	}
+	// 任一条目未通过检查时整体不生效
+	registry := NewRegistry()
+	fmt.Println(registry.Load(path))
+	fmt.Println(registry.RegisterRange("spider", 40000, 40999))
+	fmt.Println(registry.Get(40001, "").Code)
+	// Output:
+	// 信息码10001已存在
+	// <nil>
+	// 99999
+}
+
+func ExampleNewRotateWriter() {
+	dir, err := os.MkdirTemp("", "rotate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// 超过1MB时切分，保留最近2个历史文件并压缩
+	w, err := NewRotateWriter(FileOption{Path: filepath.Join(dir, "app.log"), MaxSize: 1, MaxBackups: 2, Compress: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.Write(bytes.Repeat([]byte("-"), MEGABYTE))
+	w.Write([]byte("second\n"))
+	// 同一毫秒内多次切分时历史文件名追加序号，不会相互覆盖
+	w.Rotate()
+	w.Write([]byte("third\n"))
+	w.Rotate()
+	w.Write([]byte("fourth\n"))
+	fmt.Println(w.Close())
+	for _, name := range w.backups() {
+		file, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		content, _ := io.ReadAll(gz)
+		file.Close()
+		fmt.Printf("%s %q\n", filepath.Ext(name), content)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	fmt.Printf("%q\n", content)
+	// Output:
+	// <nil>
+	// .gz "second\n"
+	// .gz "third\n"
+	// "fourth\n"
+}
+
+func ExampleLoggerFactory() {
+	ctx := trace.WithTrace(context.Background(), "t06")
+	_, err := LoggerFactory(ctx, "logrus", Option{Application: "zap-log", Env: "dev", Label: "detail"})
+	fmt.Println(err)
+	// Output:
+	// 不支持的类型: logrus
+}
+
+func ExampleNewRotateWriter_daily() {
+	dir, err := os.MkdirTemp("", "rotate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// 前一天写入的文件在今天首次写入时切分，历史文件使用前一天的日期
+	path := filepath.Join(dir, "app.log")
+	yesterday := time.Now().AddDate(0, 0, -1)
+	if err := os.WriteFile(path, []byte("yesterday\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+	os.Chtimes(path, yesterday, yesterday)
+	w, err := NewRotateWriter(FileOption{Path: path, Daily: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.Write([]byte("today\n"))
+	fmt.Println(w.Close())
+	backups := w.backups()
+	fmt.Println(len(backups), strings.HasPrefix(filepath.Base(backups[0]), "app-"+yesterday.Format("20060102")))
+	// 关闭后写入返回错误，不会重新打开文件
+	_, err = w.Write([]byte("closed\n"))
+	fmt.Println(errors.Is(err, os.ErrClosed))
+	// Output:
+	// <nil>
+	// 1 true
+	// true
+}
+
+func ExampleZapLogger_Close() {
+	ctx := trace.WithTrace(context.Background(), "t07")
+	dir, err := os.MkdirTemp("", "logger")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := New(ctx, Zap, Option{
+		Application: "zap-log",
+		Env:         "dev",
+		Label:       "detail",
+		Writers:     []WriterType{File},
+		File:        FileOption{Path: filepath.Join(dir, "app.log")},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.Info(ctx, Message{Text: "写入文件"})
+	// 关闭时写入缓冲的日志并关闭文件，进程退出时由 lifecycle 调用
+	fmt.Println(c.Close(ctx))
+	fmt.Println(c.Close(ctx))
+	content, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	fmt.Println(strings.Contains(string(content), "写入文件"))
+	// Output:
+	// <nil>
+	// <nil>
+	// true
+}
+
+func ExampleZapLogger_trace() {
+	ctx := context.Background()
+	dir, err := os.MkdirTemp("", "logger")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := New(ctx, Zap, Option{
+		Application: "zap-log",
+		Env:         "dev",
+		Label:       "detail",
+		Writers:     []WriterType{File},
+		File:        FileOption{Path: filepath.Join(dir, "app.log")},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 未设置追踪编码时自动生成
+	c.Info(ctx, Message{Text: "缺少追踪编码"})
+	c.Close(ctx)
+	content, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	var entry map[string]interface{}
+	json.Unmarshal(content, &entry)
+	fmt.Println(len(entry["trace"].(string)) > 0)
+	// Output:
+	// true
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// 日志级别接口的请求与响应内容
+type levelPayload struct {
+	Level LoggerLevel `json:"level"`
+}
+
+// 用于在运行时查询和调整日志级别的接口
+//
+// GET 返回当前级别，PUT 提交 {"level": "debug"} 调整级别，未初始化默认对象时返回503
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		switch r.Method {
+		case http.MethodGet:
+			writeLevel(w, encoder)
+		case http.MethodPut:
+			var payload levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				encoder.Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			if err := SetLevel(payload.Level); err != nil {
+				w.WriteHeader(statusOf(err))
+				encoder.Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			writeLevel(w, encoder)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			encoder.Encode(map[string]string{"error": "仅支持GET与PUT"})
+		}
+	})
+}
+
+func writeLevel(w http.ResponseWriter, encoder *json.Encoder) {
+	level, err := GetLevel()
+	if err != nil {
+		w.WriteHeader(statusOf(err))
+		encoder.Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	encoder.Encode(levelPayload{Level: level})
+}
+
+func statusOf(err error) int {
+	if errors.Is(err, ErrNotInitialized) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
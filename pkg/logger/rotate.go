@@ -0,0 +1,281 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_FILE_MODE = 0644
+	DEFAULT_DIR_MODE  = 0755
+	// 历史文件的时间格式
+	ROTATE_TIME_LAYOUT = "20060102-150405.000"
+	COMPRESS_SUFFIX    = ".gz"
+	MEGABYTE           = 1024 * 1024
+)
+
+// 文件输出参数
+type FileOption struct {
+	Path       string `json:"path" label:"文件路径"`
+	MaxSize    int    `json:"max_size" label:"单个文件最大容量" desc:"单位为MB，为0时不按容量切分"`
+	Daily      bool   `json:"daily" label:"是否按天切分" desc:"默认不开启"`
+	MaxBackups int    `json:"max_backups" label:"历史文件保留数" desc:"为0时全部保留"`
+	Compress   bool   `json:"compress" label:"是否压缩历史文件" desc:"使用gzip压缩"`
+}
+
+// 支持按容量和按天切分的文件输出
+type RotateWriter struct {
+	option FileOption
+	file   *os.File
+	size   int64
+	day    string
+	start  time.Time // 当前文件的起始时间，用于历史文件名称
+	closed bool
+	mu     sync.Mutex
+	mill   sync.Mutex
+	wg     sync.WaitGroup
+}
+
+// 创建文件输出对象
+func NewRotateWriter(option FileOption) (*RotateWriter, error) {
+	if len(option.Path) == 0 {
+		return nil, errors.New("文件路径不能为空")
+	}
+	w := &RotateWriter{option: option}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// 关闭文件，并等待历史文件压缩与清理完成，关闭后写入返回 os.ErrClosed
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	err := w.close()
+	w.mu.Unlock()
+	w.wg.Wait()
+	return err
+}
+
+// 立即切分
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+func (w *RotateWriter) shouldRotate(incoming int64) bool {
+	if w.option.MaxSize > 0 && w.size > 0 && w.size+incoming > int64(w.option.MaxSize)*MEGABYTE {
+		return true
+	}
+	if w.option.Daily && w.day != time.Now().Format("20060102") {
+		return true
+	}
+	return false
+}
+
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.option.Path), DEFAULT_DIR_MODE); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.option.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, DEFAULT_FILE_MODE)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	// 重新打开已有内容的文件时使用其修改时间
+	w.start = info.ModTime()
+	if info.Size() == 0 {
+		w.start = time.Now()
+	}
+	w.day = w.start.Format("20060102")
+	return nil
+}
+
+func (w *RotateWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotateWriter) rotate() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	// 使用被切分文件的起始时间，按天切分时历史文件为前一天的日期
+	backup := w.backupName(w.start)
+	if err := os.Rename(w.option.Path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.wg.Add(1)
+	go w.millRun(backup)
+	return nil
+}
+
+// 历史文件名称，例如 app-20220102-150405.000.log，起始时间相同时追加序号，例如 app-20220102-150405.000.1.log
+func (w *RotateWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	stamp := t.Format(ROTATE_TIME_LAYOUT)
+	name := filepath.Join(dir, prefix+stamp+ext)
+	for i := 1; exists(name) || exists(name+COMPRESS_SUFFIX); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s%s.%d%s", prefix, stamp, i, ext))
+	}
+	return name
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func (w *RotateWriter) nameParts() (string, string, string) {
+	dir := filepath.Dir(w.option.Path)
+	filename := filepath.Base(w.option.Path)
+	ext := filepath.Ext(filename)
+	return dir, strings.TrimSuffix(filename, ext) + "-", ext
+}
+
+// 压缩与清理历史文件
+func (w *RotateWriter) millRun(backup string) {
+	defer w.wg.Done()
+	w.mill.Lock()
+	defer w.mill.Unlock()
+	if w.option.Compress {
+		if err := compressFile(backup); err == nil {
+			os.Remove(backup)
+		}
+	}
+	if w.option.MaxBackups > 0 {
+		backups := w.backups()
+		for i := 0; i < len(backups)-w.option.MaxBackups; i++ {
+			os.Remove(backups[i])
+		}
+	}
+}
+
+// 按时间先后排列的历史文件，同一时间的按序号排列
+func (w *RotateWriter) backups() []string {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	type backup struct {
+		path  string
+		stamp string
+		seq   int
+	}
+	result := []backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], COMPRESS_SUFFIX), ext)
+		if len(rest) < len(ROTATE_TIME_LAYOUT) {
+			continue
+		}
+		stamp, suffix := rest[:len(ROTATE_TIME_LAYOUT)], rest[len(ROTATE_TIME_LAYOUT):]
+		if _, err := time.Parse(ROTATE_TIME_LAYOUT, stamp); err != nil {
+			continue
+		}
+		seq := 0
+		if len(suffix) > 0 {
+			if seq, err = strconv.Atoi(strings.TrimPrefix(suffix, ".")); err != nil || !strings.HasPrefix(suffix, ".") || seq < 1 {
+				continue
+			}
+		}
+		result = append(result, backup{path: filepath.Join(dir, name), stamp: stamp, seq: seq})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].stamp != result[j].stamp {
+			return result[i].stamp < result[j].stamp
+		}
+		return result[i].seq < result[j].seq
+	})
+	paths := make([]string, len(result))
+	for i, value := range result {
+		paths[i] = value.path
+	}
+	return paths
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+COMPRESS_SUFFIX, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, DEFAULT_FILE_MODE)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(name + COMPRESS_SUFFIX)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
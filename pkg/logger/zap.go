@@ -4,9 +4,12 @@ package logger
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"sync"
 
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -21,12 +24,19 @@ type EncoderType string
 // 使用枚举限定选择
 type LoggerLevel string
 
+// 使用枚举限定选择
+type WriterType string
+
 const (
 	// SupportType
 	Zap SupportType = "zap" // Zap 日志包
 	// EncoderType
 	Json    EncoderType = "json"    // Json Encoder
 	Console EncoderType = "console" // Console Encoder
+	// WriterType
+	Stdout WriterType = "stdout" // 标准输出
+	Stderr WriterType = "stderr" // 标准错误输出
+	File   WriterType = "file"   // 文件输出
 	// LoggerLevel
 	DEBUG LoggerLevel = "debug"
 	INFO  LoggerLevel = "info"
@@ -37,13 +47,19 @@ const (
 
 const DEFAULT_CODE = SUCCESS
 const DEFAULT_LEVEL = INFO
+const DEFAULT_MIN_LEVEL = INFO
 
 // 定义全局配置对象
-var logger Logger
-var once sync.Once
+var instances = map[string]Logger{}
+var mu sync.RWMutex
+
+var ErrNotInitialized = errors.New("日志对象未初始化")
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
 
 func init() {
-	ctx := context.WithValue(context.Background(), "trace", "init-for-logger")
+	ctx := trace.WithTrace(context.Background(), "init-for-logger")
 	validate.InitValidate(ctx, "validator", validate.Option{})
 }
 
@@ -54,6 +70,9 @@ type Logger interface {
 	Warn(ctx context.Context, message Message)
 	Error(ctx context.Context, message Message)
 	Fatal(ctx context.Context, message Message)
+	SetLevel(level LoggerLevel) error
+	GetLevel() LoggerLevel
+	Close(ctx context.Context) error
 }
 
 type Field = zapcore.Field
@@ -63,37 +82,68 @@ type EncoderConfig = zapcore.EncoderConfig
 
 // 初始化时所用参数
 type Option struct {
-	Application string      `json:"application" label:"应用名称" desc:"必须与远端配置名称相同" validate:"required"`
-	Env         string      `json:"env" label:"环境" desc:"推荐不同环境不同配置" validate:"required"`
-	Label       string      `json:"label" label:"别称" desc:"用于后续日志细分" validate:"required"`
-	Encode      EncoderType `json:"encoder" label:"输出格式" desc:""`
+	Application string       `json:"application" label:"应用名称" desc:"必须与远端配置名称相同" validate:"required"`
+	Env         string       `json:"env" label:"环境" desc:"推荐不同环境不同配置" validate:"required"`
+	Label       string       `json:"label" label:"别称" desc:"用于后续日志细分" validate:"required"`
+	Encode      EncoderType  `json:"encoder" label:"输出格式" desc:""`
+	Writers     []WriterType `json:"writers" label:"输出方式" desc:"默认为标准输出，可同时指定多个"`
+	File        FileOption   `json:"file" label:"文件输出参数" desc:"输出方式包含文件时使用"`
+	Level       LoggerLevel  `json:"level" label:"最低日志级别" desc:"默认为info，运行时可调整" validate:"omitempty,oneof=debug info warn error fatal"`
 }
 
-// 初始化对象
+// 初始化默认对象，重复调用时替换默认对象
 func InitLogger(ctx context.Context, name SupportType, option Option) error {
-	c := logger
-	var err error
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Logger, error) {
 	message, err := validate.Work(ctx, option)
 	if err != nil {
-		return errors.New(message)
+		return nil, errors.New(message)
 	}
-	once.Do(func() {
-		c = LoggerFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		logger = c
-	})
-	return err
+	c, err := LoggerFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
+}
+
+// 注册命名对象，名称已存在时替换并关闭原对象
+func Register(key string, c Logger) {
+	mu.Lock()
+	replaced := instances[key]
+	instances[key] = c
+	mu.Unlock()
+	if replaced != nil && replaced != c {
+		replaced.Close(context.Background())
+	}
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Logger {
+	return Use(DEFAULT_INSTANCE)
 }
 
 // 抽象工厂
-func LoggerFactory(ctx context.Context, name SupportType, option Option) Logger {
+func LoggerFactory(ctx context.Context, name SupportType, option Option) (Logger, error) {
 	switch name {
 	case Zap:
 		return NewZapLogger(ctx, option)
 	default:
-		return NewZapLogger(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %s", name)
 	}
 }
 
@@ -101,9 +151,13 @@ func LoggerFactory(ctx context.Context, name SupportType, option Option) Logger
 // 基于 Zap
 type ZapLogger struct {
 	Kernel      *zap.Logger
-	Env         string `json:"env" label:"环境"`
-	Application string `json:"application"  label:"应用名称"`
-	Label       string `json:"label" label:"别称"`
+	Atom        zap.AtomicLevel
+	Env         string      `json:"env" label:"环境"`
+	Application string      `json:"application"  label:"应用名称"`
+	Label       string      `json:"label" label:"别称"`
+	closers     []io.Closer // 需要关闭的输出，例如文件
+	once        sync.Once
+	err         error
 }
 
 // 日志信息主体
@@ -113,6 +167,7 @@ type Message struct {
 	Label     string `json:"label" label:"别称"`
 	Remark    string `json:"remark" label:"备注"`
 	Traceback string `json:"traceback" label:"错误栈信息"`
+	Error     error  `json:"-" label:"错误" desc:"用于自动填充信息码、级别与错误栈信息"`
 }
 
 // 监控相关的日志字段
@@ -131,7 +186,7 @@ type Attr struct {
 }
 
 // 创建基于Zap的日志对象
-func NewZapLogger(ctx context.Context, option Option) Logger {
+func NewZapLogger(ctx context.Context, option Option) (Logger, error) {
 	enc := zapcore.EncoderConfig{
 		TimeKey:        "when",
 		LevelKey:       "level",
@@ -145,24 +200,91 @@ func NewZapLogger(ctx context.Context, option Option) Logger {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 	// 构建输出方式
-	zapWriterSync := zapcore.AddSync(os.Stdout)
+	zapWriterSync, closers, err := applyWriter(option)
+	if err != nil {
+		return nil, fmt.Errorf("构建输出方式失败: %w", err)
+	}
 	// 构建输出格式
 	encoder := applyEncoder(option.Encode, enc)
+	// 构建可在运行时调整的日志级别
+	if option.Level == "" {
+		option.Level = DEFAULT_MIN_LEVEL
+	}
+	level, err := toZapLevel(option.Level)
+	if err != nil {
+		closeAll(closers)
+		return nil, err
+	}
+	atom := zap.NewAtomicLevelAt(level)
 	// 应用参数
 	zapCore := zapcore.NewCore(
 		encoder,
 		zapWriterSync,
-		zapcore.InfoLevel,
+		atom,
 	)
 	// 根据参数创建日志对象
 	logger := zap.New(zapCore, zap.AddCaller(), zap.AddCallerSkip(3))
 	defer logger.Sync()
 	return &ZapLogger{
 		Kernel:      logger,
+		Atom:        atom,
 		Env:         option.Env,
 		Application: option.Application,
 		Label:       option.Label,
+		closers:     closers,
+	}, nil
+}
+
+// 写入缓冲的日志后关闭文件等输出，重复调用时返回首次关闭的结果
+func (c *ZapLogger) Close(ctx context.Context) error {
+	c.once.Do(func() {
+		// 标准输出不支持Sync时会返回错误，只返回关闭输出的错误
+		c.Kernel.Sync()
+		c.err = closeAll(c.closers)
+	})
+	return c.err
+}
+
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, closer := range closers {
+		if e := closer.Close(); err == nil {
+			err = e
+		}
 	}
+	return err
+}
+
+// 应用输出方式，同时返回需要关闭的输出
+func applyWriter(option Option) (zapcore.WriteSyncer, []io.Closer, error) {
+	if len(option.Writers) == 0 {
+		option.Writers = []WriterType{Stdout}
+	}
+	writers := []zapcore.WriteSyncer{}
+	closers := []io.Closer{}
+	for _, types := range option.Writers {
+		switch types {
+		case Stdout:
+			writers = append(writers, zapcore.AddSync(os.Stdout))
+		case Stderr:
+			writers = append(writers, zapcore.AddSync(os.Stderr))
+		case File:
+			w, err := NewRotateWriter(option.File)
+			if err != nil {
+				closeAll(closers)
+				return nil, nil, err
+			}
+			writers = append(writers, zapcore.AddSync(w))
+			closers = append(closers, w)
+		default:
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("不支持的输出方式: %s", types)
+		}
+	}
+	if len(writers) == 1 {
+		return writers[0], closers, nil
+	}
+	return zapcore.NewMultiWriteSyncer(writers...), closers, nil
 }
 
 // 应用输出格式
@@ -178,6 +300,9 @@ func applyEncoder(types EncoderType, enc EncoderConfig) Encoder {
 }
 
 func (c *ZapLogger) build(ctx context.Context, message Message) []zapcore.Field {
+	if message.Error != nil {
+		message = applyError(message)
+	}
 	if message.Attr.Monitor.Code == 0 {
 		message.Attr.Monitor.Code = DEFAULT_CODE
 	}
@@ -188,7 +313,7 @@ func (c *ZapLogger) build(ctx context.Context, message Message) []zapcore.Field
 		message.Label = c.Label
 	}
 	content := map[string]zapcore.Field{
-		"trace":       zap.String("trace", ctx.Value("trace").(string)),
+		"trace":       zap.String("trace", trace.TraceFrom(ctx)),
 		"env":         zap.String("env", c.Env),
 		"application": zap.String("application", c.Application),
 		"label":       zap.String("label", message.Label),
@@ -203,11 +328,67 @@ func (c *ZapLogger) build(ctx context.Context, message Message) []zapcore.Field
 	return result
 }
 
+// 调整最低日志级别，立即生效
+func (c *ZapLogger) SetLevel(level LoggerLevel) error {
+	value, err := toZapLevel(level)
+	if err != nil {
+		return err
+	}
+	c.Atom.SetLevel(value)
+	return nil
+}
+
+// 获取当前的最低日志级别
+func (c *ZapLogger) GetLevel() LoggerLevel {
+	return LoggerLevel(c.Atom.Level().String())
+}
+
+func toZapLevel(level LoggerLevel) (zapcore.Level, error) {
+	switch level {
+	case DEBUG:
+		return zapcore.DebugLevel, nil
+	case INFO:
+		return zapcore.InfoLevel, nil
+	case WARN:
+		return zapcore.WarnLevel, nil
+	case ERROR:
+		return zapcore.ErrorLevel, nil
+	case FATAL:
+		return zapcore.FatalLevel, nil
+	default:
+		return zapcore.InfoLevel, fmt.Errorf("不支持的日志级别: %s", level)
+	}
+}
+
+// 根据错误填充信息码、级别与错误栈信息
+func applyError(message Message) Message {
+	var e *CodeError
+	if errors.As(message.Error, &e) {
+		if message.Attr.Monitor.Code == 0 {
+			message.Attr.Monitor.Code = e.Code
+		}
+		if message.Attr.Monitor.Level == "" {
+			message.Attr.Monitor.Level = e.Level
+		}
+		if message.Traceback == "" {
+			message.Traceback = e.Traceback()
+		}
+	} else if message.Traceback == "" {
+		message.Traceback = message.Error.Error()
+	}
+	if message.Text == "" {
+		message.Text = message.Error.Error()
+	}
+	return message
+}
+
+// 未设置追踪编码时自动生成，保证每条日志均可追踪
 func (c *ZapLogger) write(ctx context.Context, level string, message Message) {
+	ctx = trace.EnsureTrace(ctx)
 	content := c.build(ctx, message)
 	switch level {
 	case string(DEBUG):
-		c.Kernel.Info(message.Text, content...)
+		c.Kernel.Debug(message.Text, content...)
 	case string(INFO):
 		c.Kernel.Info(message.Text, content...)
 	case string(WARN):
@@ -239,17 +420,50 @@ func (c *ZapLogger) Fatal(ctx context.Context, message Message) {
 }
 
 func Debug(ctx context.Context, message Message) {
-	logger.Debug(ctx, message)
+	Default().Debug(ctx, message)
 }
 func Info(ctx context.Context, message Message) {
-	logger.Info(ctx, message)
+	Default().Info(ctx, message)
 }
 func Warn(ctx context.Context, message Message) {
-	logger.Warn(ctx, message)
+	Default().Warn(ctx, message)
 }
 func Error(ctx context.Context, message Message) {
-	logger.Error(ctx, message)
+	Default().Error(ctx, message)
 }
 func Fatal(ctx context.Context, message Message) {
-	logger.Fatal(ctx, message)
+	Default().Fatal(ctx, message)
+}
+
+// 关闭全部命名对象，进程退出时由 lifecycle 在其他组件之后调用
+func Close(ctx context.Context) error {
+	mu.RLock()
+	closers := make([]Logger, 0, len(instances))
+	for _, c := range instances {
+		closers = append(closers, c)
+	}
+	mu.RUnlock()
+	var err error
+	for _, c := range closers {
+		if e := c.Close(ctx); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// 未初始化默认对象时返回 ErrNotInitialized
+func SetLevel(level LoggerLevel) error {
+	c := Default()
+	if c == nil {
+		return ErrNotInitialized
+	}
+	return c.SetLevel(level)
+}
+func GetLevel() (LoggerLevel, error) {
+	c := Default()
+	if c == nil {
+		return "", ErrNotInitialized
+	}
+	return c.GetLevel(), nil
 }
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 func ExampleRabbitMessenger() {
-	ctx := context.WithValue(context.Background(), "trace", "ctx-messenger-001")
+	ctx := trace.WithTrace(context.Background(), "ctx-messenger-001")
 	option := Option{
 		Host:      "localhost:5672",
 		Auth:      true,
@@ -23,7 +25,7 @@ func ExampleRabbitMessenger() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	consumeObject, err := messenger.CreateConsume(ctx)
+	consumeObject, err := CreateConsume(ctx)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -33,8 +35,8 @@ func ExampleRabbitMessenger() {
 		case carton := <-cosm.Consume:
 			fmt.Println(map[string]interface{}{"p": carton.Priority, "m": string(carton.Body)})
 			carton.Ack(false)
-			messenger.Sent(ctx, SentPayload{
-				Topic:    messenger.GetTopic().Product,
+			Sent(ctx, SentPayload{
+				Topic:    GetTopic().Product,
 				Message:  fmt.Sprintf("abc-%s", string(carton.Body)),
 				Priority: 5,
 				Channel:  cosm.Channel,
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aivencs/magic-box/pkg/lifecycle"
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 	"github.com/streadway/amqp"
 )
@@ -21,12 +23,14 @@ const (
 )
 
 // 定义全局配置对象
-var messenger Messenger
+var instances = map[string]Messenger{}
+var mu sync.RWMutex
 
-var once sync.Once
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
 
 func init() {
-	ctx := context.WithValue(context.Background(), "trace", "init-for-messenger")
+	ctx := trace.WithTrace(context.Background(), "init-for-messenger")
 	validate.InitValidate(ctx, "validator", validate.Option{})
 }
 
@@ -36,6 +40,7 @@ type Messenger interface {
 	GetConnect() interface{}
 	GetTopic() Topic
 	Sent(ctx context.Context, payload SentPayload) error
+	Close(ctx context.Context) error
 }
 
 // 初始化时所用参数
@@ -57,31 +62,57 @@ type SentPayload struct {
 	Channel  interface{} `json:"channel" label:"信道" validate:"required"`
 }
 
-// 初始化对象
+// 初始化默认对象，重复调用时替换默认对象
 func InitMessenger(ctx context.Context, name SupportType, option Option) error {
-	var c = messenger
-	var err error
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Messenger, error) {
 	message, err := validate.Work(ctx, option)
 	if err != nil {
-		return errors.New(message)
+		return nil, errors.New(message)
 	}
-	once.Do(func() {
-		c = MessengerFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		messenger = c
-	})
-	return err
+	c, err := MessengerFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
+}
+
+// 注册命名对象，名称已存在时替换并关闭原对象，进程退出时由 lifecycle 关闭
+func Register(key string, c Messenger) {
+	mu.Lock()
+	instances[key] = c
+	mu.Unlock()
+	// 关闭原对象可能耗时较长，释放锁后再注册，避免阻塞获取对象
+	lifecycle.Register("messenger:"+key, c)
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Messenger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Messenger {
+	return Use(DEFAULT_INSTANCE)
 }
 
 // 抽象工厂
-func MessengerFactory(ctx context.Context, name SupportType, option Option) Messenger {
+func MessengerFactory(ctx context.Context, name SupportType, option Option) (Messenger, error) {
 	switch name {
 	case RABBIT:
 		return NewRabbitMessenger(ctx, option)
 	default:
-		return NewRabbitMessenger(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %s", name)
 	}
 }
 
@@ -107,7 +138,7 @@ type RabbitConsume struct {
 }
 
 // 创建基于Rabbitmq的对象
-func NewRabbitMessenger(ctx context.Context, option Option) Messenger {
+func NewRabbitMessenger(ctx context.Context, option Option) (Messenger, error) {
 	conf := amqp.Config{
 		Heartbeat: time.Second * time.Duration(option.Heartbeat),
 	}
@@ -117,7 +148,7 @@ func NewRabbitMessenger(ctx context.Context, option Option) Messenger {
 	}
 	conn, err := amqp.DialConfig(address, conf)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("连接%s%s失败: %w", option.Host, option.Zone, err)
 	}
 	if option.Qos == 0 {
 		option.Qos = DEFAULT_QOS
@@ -126,7 +157,7 @@ func NewRabbitMessenger(ctx context.Context, option Option) Messenger {
 		Topic:   option.Topic,
 		Connect: conn,
 		Qos:     option.Qos,
-	}
+	}, nil
 }
 
 func (c *RabbitMessenger) Sent(ctx context.Context, payload SentPayload) error {
@@ -171,18 +202,51 @@ func (c *RabbitMessenger) CreateConsume(ctx context.Context) (interface{}, error
 	return RabbitConsume{Consume: consume, Channel: chl}, err
 }
 
+// 关闭信道与连接，ctx 结束时不再等待，重复调用时直接返回
+func (c *RabbitMessenger) Close(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		if c.Channel != nil {
+			err = ignoreClosed(c.Channel.Close())
+		}
+		if e := ignoreClosed(c.Connect.Close()); err == nil {
+			err = e
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 已关闭不视为错误
+func ignoreClosed(err error) error {
+	if err == amqp.ErrClosed {
+		return nil
+	}
+	return err
+}
+
 func CreateConsume(ctx context.Context) (interface{}, error) {
-	return messenger.CreateConsume(ctx)
+	return Default().CreateConsume(ctx)
 }
 
 func GetConnect() interface{} {
-	return messenger.GetConnect()
+	return Default().GetConnect()
 }
 
 func GetTopic() Topic {
-	return messenger.GetTopic()
+	return Default().GetTopic()
 }
 
 func Sent(ctx context.Context, payload SentPayload) error {
-	return messenger.Sent(ctx, payload)
+	return Default().Sent(ctx, payload)
+}
+
+func Close(ctx context.Context) error {
+	return Default().Close(ctx)
 }
@@ -0,0 +1,587 @@
+package redisconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// 集群槽位数量
+	CLUSTER_SLOTS = 16384
+	// 定义默认值
+	DEFAULT_MAX_REDIRECTS = 5
+	DEFAULT_REFRESH_DELAY = 100 * time.Millisecond
+)
+
+// 集群模式下不支持的命令
+var ErrClusterUnsupported = errors.New("集群模式不支持该命令")
+
+// 集群的槽位与节点信息
+type cluster struct {
+	option  Option
+	dialer  []redigo.DialOption
+	slots   [CLUSTER_SLOTS]string
+	nodes   map[string]*redigo.Pool
+	seeds   []string
+	updated time.Time
+	closed  bool
+	mu      sync.RWMutex
+	refresh sync.Mutex
+}
+
+func newCluster(option Option, dialer []redigo.DialOption) *cluster {
+	return &cluster{
+		option: option,
+		dialer: dialer,
+		nodes:  map[string]*redigo.Pool{},
+		seeds:  append([]string{}, option.Addrs...),
+	}
+}
+
+// 连接池借出的连接，按命令的键路由到对应节点
+func (c *cluster) dial() (redigo.Conn, error) {
+	if err := c.ready(); err != nil {
+		return nil, err
+	}
+	return &clusterConn{cluster: c}, nil
+}
+
+// 尚未获取槽位分布时先获取
+func (c *cluster) ready() error {
+	c.mu.RLock()
+	ready := !c.updated.IsZero()
+	c.mu.RUnlock()
+	if ready {
+		return nil
+	}
+	return c.reload(true)
+}
+
+// 重新获取槽位分布，force 为 false 时限制刷新频率
+func (c *cluster) reload(force bool) error {
+	c.refresh.Lock()
+	defer c.refresh.Unlock()
+	c.mu.RLock()
+	recent := time.Since(c.updated) < DEFAULT_REFRESH_DELAY
+	addrs := append([]string{}, c.seeds...)
+	for addr := range c.nodes {
+		addrs = append(addrs, addr)
+	}
+	c.mu.RUnlock()
+	if recent && !force {
+		return nil
+	}
+	var lastErr error
+	for _, addr := range addrs {
+		slots, err := c.query(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c.mu.Lock()
+		c.slots = slots
+		c.updated = time.Now()
+		c.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("获取集群槽位失败: %w", lastErr)
+}
+
+func (c *cluster) query(addr string) ([CLUSTER_SLOTS]string, error) {
+	var slots [CLUSTER_SLOTS]string
+	r := c.pool(addr).Get()
+	defer r.Close()
+	ranges, err := redigo.Values(r.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return slots, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	for _, item := range ranges {
+		fields, err := redigo.Values(item, nil)
+		if err != nil || len(fields) < 3 {
+			return slots, errors.New("集群槽位格式错误")
+		}
+		start, _ := redigo.Int(fields[0], nil)
+		end, _ := redigo.Int(fields[1], nil)
+		node, err := redigo.Values(fields[2], nil)
+		if err != nil || len(node) < 2 {
+			return slots, errors.New("集群节点格式错误")
+		}
+		ip, _ := redigo.String(node[0], nil)
+		port, _ := redigo.Int(node[1], nil)
+		// 节点地址为空时与当前节点相同
+		if len(ip) == 0 {
+			ip = host
+		}
+		master := net.JoinHostPort(ip, strconv.Itoa(port))
+		for slot := start; slot <= end && slot < CLUSTER_SLOTS; slot++ {
+			slots[slot] = master
+		}
+	}
+	return slots, nil
+}
+
+// 获取节点的连接池，不存在时创建
+func (c *cluster) pool(addr string) *redigo.Pool {
+	c.mu.RLock()
+	p, ok := c.nodes[addr]
+	c.mu.RUnlock()
+	if ok {
+		return p
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.nodes[addr]; ok {
+		return p
+	}
+	p = newPool(c.option, func() (redigo.Conn, error) {
+		return redigo.Dial("tcp", addr, c.dialer...)
+	}, ping)
+	if c.closed {
+		// 关闭后不再保留新的连接池，借出的连接均返回错误
+		p.Close()
+		return p
+	}
+	c.nodes[addr] = p
+	return p
+}
+
+// 关闭全部节点的连接池，返回首个错误
+func (c *cluster) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	var first error
+	for addr, p := range c.nodes {
+		if err := p.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(c.nodes, addr)
+	}
+	return first
+}
+
+// 当前已知节点的连接池
+func (c *cluster) pools() map[string]*redigo.Pool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pools := make(map[string]*redigo.Pool, len(c.nodes))
+	for addr, p := range c.nodes {
+		pools[addr] = p
+	}
+	return pools
+}
+
+// 持有槽位的节点
+func (c *cluster) masters() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	seen := map[string]bool{}
+	addrs := []string{}
+	for _, addr := range c.slots {
+		if len(addr) > 0 && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// 槽位对应的节点，key 为空时返回任意节点
+func (c *cluster) addr(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(key) > 0 {
+		if addr := c.slots[Slot(key)]; len(addr) > 0 {
+			return addr
+		}
+	}
+	for _, addr := range c.slots {
+		if len(addr) > 0 {
+			return addr
+		}
+	}
+	if len(c.seeds) > 0 {
+		return c.seeds[0]
+	}
+	return ""
+}
+
+// 执行命令，多个键的命令在键位于不同槽位时按槽位拆分执行
+func (c *cluster) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if groups := slotGroups(cmd, args); len(groups) > 1 {
+		return c.doSplit(ctx, cmd, args, groups)
+	}
+	return c.route(ctx, cmd, args...)
+}
+
+// 拆分后各槽位依次执行并合并结果，跨槽位时不保证原子性，遇到错误时返回该错误
+func (c *cluster) doSplit(ctx context.Context, cmd string, args []interface{}, groups [][]int) (interface{}, error) {
+	name := strings.ToUpper(cmd)
+	step := multiKey[name]
+	values := make([]interface{}, len(args)/step)
+	var count int64
+	var status interface{}
+	for _, group := range groups {
+		part := make([]interface{}, 0, len(group)*step)
+		for _, i := range group {
+			part = append(part, args[i*step:(i+1)*step]...)
+		}
+		reply, err := c.route(ctx, cmd, part...)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "MGET":
+			items, err := redigo.Values(reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(items) != len(group) {
+				return nil, errors.New("批量读取的结果数量不一致")
+			}
+			for j, i := range group {
+				values[i] = items[j]
+			}
+		case "MSET":
+			status = reply
+		default:
+			n, err := redigo.Int64(reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			count += n
+		}
+	}
+	switch name {
+	case "MGET":
+		return values, nil
+	case "MSET":
+		return status, nil
+	}
+	return count, nil
+}
+
+// 可按槽位拆分的多键命令，值为每组参数的数量
+var multiKey = map[string]int{
+	"MGET":   1,
+	"MSET":   2,
+	"DEL":    1,
+	"UNLINK": 1,
+	"EXISTS": 1,
+	"TOUCH":  1,
+}
+
+// 按槽位分组的参数组序号，保持首次出现的顺序，不可拆分时返回 nil
+func slotGroups(cmd string, args []interface{}) [][]int {
+	step, ok := multiKey[strings.ToUpper(cmd)]
+	if !ok || len(args) <= step || len(args)%step != 0 {
+		return nil
+	}
+	index := map[int]int{}
+	groups := [][]int{}
+	for i := 0; i < len(args)/step; i++ {
+		slot := Slot(argString(args[i*step]))
+		n, ok := index[slot]
+		if !ok {
+			n = len(groups)
+			index[slot] = n
+			groups = append(groups, nil)
+		}
+		groups[n] = append(groups[n], i)
+	}
+	return groups
+}
+
+// 按键路由执行命令并处理 MOVED 与 ASK 重定向
+func (c *cluster) route(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	addr := c.addr(keyOf(cmd, args))
+	asking := false
+	for i := 0; ; i++ {
+		reply, err := c.doNode(ctx, addr, asking, cmd, args...)
+		redirect, ok := err.(redigo.Error)
+		if err != nil && !ok && ctx.Err() == nil {
+			// 节点不可用时可能已发生故障转移
+			go c.reload(false)
+		}
+		if !ok || i >= DEFAULT_MAX_REDIRECTS {
+			return reply, err
+		}
+		fields := strings.Fields(string(redirect))
+		switch {
+		case len(fields) == 3 && fields[0] == "MOVED":
+			// 槽位已迁移，更新后重试
+			addr, asking = fields[2], false
+			c.move(fields[1], addr)
+		case len(fields) == 3 && fields[0] == "ASK":
+			// 槽位迁移中，仅本次请求到目标节点
+			addr, asking = fields[2], true
+		case len(fields) > 0 && fields[0] == "TRYAGAIN":
+			time.Sleep(DEFAULT_REFRESH_DELAY)
+		default:
+			return reply, err
+		}
+	}
+}
+
+func (c *cluster) doNode(ctx context.Context, addr string, asking bool, cmd string, args ...interface{}) (interface{}, error) {
+	r, err := c.pool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if asking {
+		if _, err := redigo.DoContext(r, ctx, "ASKING"); err != nil {
+			return nil, err
+		}
+	}
+	return redigo.DoContext(r, ctx, cmd, args...)
+}
+
+// 记录单个槽位的新节点并在后台刷新全部槽位
+func (c *cluster) move(slot string, addr string) {
+	if n, err := strconv.Atoi(slot); err == nil && n >= 0 && n < CLUSTER_SLOTS {
+		c.mu.Lock()
+		c.slots[n] = addr
+		c.mu.Unlock()
+	}
+	go c.reload(false)
+}
+
+// 按命令的类型获取用于路由的键，multiKey 之外的多键命令需使用哈希标签保证位于同一槽位
+func keyOf(cmd string, args []interface{}) string {
+	switch strings.ToUpper(cmd) {
+	case "EVAL", "EVALSHA":
+		if len(args) > 2 {
+			if n, _ := redigo.Int(args[1], nil); n > 0 {
+				return argString(args[2])
+			}
+		}
+		return ""
+	case "PING", "ECHO", "INFO", "TIME", "PUBLISH", "SCRIPT", "CLUSTER", "COMMAND", "DBSIZE", "RANDOMKEY", "SCAN":
+		return ""
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return argString(args[0])
+}
+
+func argString(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+// 计算键所在的槽位，支持 {tag} 形式的哈希标签
+func Slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % CLUSTER_SLOTS)
+}
+
+// CRC16-CCITT(XMODEM)
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// 结构体
+// 集群连接，Do 按键路由；Send 的命令在 Receive 时依次执行，订阅类命令固定到单个节点
+//
+// 退订全部频道后连接不再复用，归还时由连接池丢弃
+type clusterConn struct {
+	cluster  *cluster
+	pending  []command
+	pinned   redigo.Conn
+	released bool // 已退订全部频道
+	err      error
+}
+
+// 连接已固定到单个节点且退订了全部频道
+var errUnsubscribed = errors.New("订阅连接已退订，不再复用")
+
+type command struct {
+	name string
+	args []interface{}
+}
+
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.DoContext(context.Background(), cmd, args...)
+}
+
+func (c *clusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.DoContext(ctx, cmd, args...)
+}
+
+func (c *clusterConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.pinned != nil {
+		return redigo.DoContext(c.pinned, ctx, cmd, args...)
+	}
+	if unsupported(cmd) {
+		return nil, ErrClusterUnsupported
+	}
+	// 与redigo一致，命令为空时返回全部待接收的回复
+	if len(cmd) == 0 {
+		replies := make([]interface{}, 0, len(c.pending))
+		for len(c.pending) > 0 {
+			reply, err := c.ReceiveContext(ctx)
+			if e, ok := err.(redigo.Error); ok {
+				reply, err = e, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			replies = append(replies, reply)
+		}
+		return replies, nil
+	}
+	if len(c.pending) > 0 {
+		if _, err := c.DoContext(ctx, ""); err != nil {
+			return nil, err
+		}
+	}
+	return c.cluster.do(ctx, cmd, args...)
+}
+
+func (c *clusterConn) Send(cmd string, args ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.pinned == nil && subscription(cmd) {
+		r := c.cluster.pool(c.cluster.addr("")).Get()
+		if err := r.Err(); err != nil {
+			r.Close()
+			return err
+		}
+		c.pinned = r
+	}
+	if c.pinned != nil {
+		return c.pinned.Send(cmd, args...)
+	}
+	if unsupported(cmd) {
+		return ErrClusterUnsupported
+	}
+	c.pending = append(c.pending, command{name: cmd, args: args})
+	return nil
+}
+
+func (c *clusterConn) Flush() error {
+	if c.pinned != nil {
+		return c.pinned.Flush()
+	}
+	return c.err
+}
+
+func (c *clusterConn) Receive() (interface{}, error) {
+	return c.ReceiveContext(context.Background())
+}
+
+func (c *clusterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	if c.pinned != nil {
+		return c.track(redigo.ReceiveWithTimeout(c.pinned, timeout))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.ReceiveContext(ctx)
+}
+
+func (c *clusterConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	if c.pinned != nil {
+		return c.track(redigo.ReceiveContext(c.pinned, ctx))
+	}
+	if len(c.pending) == 0 {
+		return nil, errors.New("没有待接收的回复")
+	}
+	next := c.pending[0]
+	c.pending = c.pending[1:]
+	return c.cluster.do(ctx, next.name, next.args...)
+}
+
+func (c *clusterConn) Err() error {
+	if c.pinned != nil {
+		if err := c.pinned.Err(); err != nil {
+			return err
+		}
+		if c.released {
+			return errUnsubscribed
+		}
+	}
+	return c.err
+}
+
+// 订阅数量降为0时标记为已退订
+func (c *clusterConn) track(reply interface{}, err error) (interface{}, error) {
+	if values, ok := reply.([]interface{}); ok && len(values) == 3 {
+		kind, _ := values[0].([]byte)
+		count, _ := values[2].(int64)
+		switch string(kind) {
+		case "unsubscribe", "punsubscribe":
+			c.released = count == 0
+		case "subscribe", "psubscribe":
+			c.released = false
+		}
+	}
+	return reply, err
+}
+
+func (c *clusterConn) Close() error {
+	if c.err == nil {
+		c.err = errors.New("连接已关闭")
+	}
+	c.pending = nil
+	if c.pinned != nil {
+		err := c.pinned.Close()
+		c.pinned = nil
+		return err
+	}
+	return nil
+}
+
+func subscription(cmd string) bool {
+	switch strings.ToUpper(cmd) {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		return true
+	}
+	return false
+}
+
+// 事务与切换数据库无法跨节点执行
+func unsupported(cmd string) bool {
+	switch strings.ToUpper(cmd) {
+	case "MULTI", "EXEC", "DISCARD", "WATCH", "SELECT":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package redisconn
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func ExampleNewPool() {
+	// 使用本地模拟的Redis服务演示，集群模式下按键所在槽位路由
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	pool, err := NewPool(context.Background(), Option{Mode: CLUSTER, Addrs: []string{server.Addr()}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := pool.Get()
+	defer r.Close()
+	fmt.Println(r.Do("SET", "{user:1}:name", "magic-box"))
+	fmt.Println(redigo.String(r.Do("GET", "{user:1}:name")))
+	// Output:
+	// OK <nil>
+	// magic-box <nil>
+}
+
+func ExampleNewPool_sentinel() {
+	// 通过哨兵发现主节点，主从切换后自动连接新的主节点
+	pool, err := NewPool(context.Background(), Option{
+		Mode:       SENTINEL,
+		Addrs:      []string{"10.0.0.1:26379", "10.0.0.2:26379", "10.0.0.3:26379"},
+		MasterName: "mymaster",
+		Auth:       true,
+		Password:   "password",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := pool.Get()
+	defer r.Close()
+	fmt.Println(r.Do("PING"))
+}
+
+func ExampleSlot() {
+	fmt.Println(Slot("123456789"))
+	fmt.Println(Slot("{user:1}:name") == Slot("{user:1}:age"))
+	// Output:
+	// 12739
+	// true
+}
+
+func ExampleNewPool_tls() {
+	// Redis 6 的ACL鉴权与TLS连接，DB 不为0时建立连接后切换数据库
+	pool, err := NewPool(context.Background(), Option{
+		Addrs:    []string{"redis.example.com:6380"},
+		Auth:     true,
+		Username: "spider",
+		Password: "password",
+		DB:       2,
+		TLS: TLSOption{
+			Enable:   true,
+			CAFile:   "/etc/redis/ca.pem",
+			CertFile: "/etc/redis/client.pem",
+			KeyFile:  "/etc/redis/client.key",
+		},
+		DialTimeout:  3 * time.Second,
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := pool.Get()
+	defer r.Close()
+	fmt.Println(r.Do("PING"))
+}
+
+func ExampleStatsOf() {
+	ctx := context.Background()
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	// 未设置的连接池参数使用默认值
+	pool, err := NewPool(ctx, Option{Addrs: []string{server.Addr()}, MaxActive: 10})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(pool.MaxIdle, pool.MaxActive, pool.IdleTimeout)
+	fmt.Println(Health(ctx, pool))
+	stats := StatsOf(pool)
+	fmt.Println(stats.ActiveCount, stats.IdleCount)
+	// Output:
+	// 10 10 2m0s
+	// <nil>
+	// 1 1
+}
+
+func ExampleNewPool_clusterSubscribe() {
+	// 两个节点各持有一半槽位，种子节点只返回槽位分布
+	low, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer low.Close()
+	high, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer high.Close()
+	seed := slotsServer(low, high)
+	defer seed.Close()
+	pool, err := NewPool(context.Background(), Option{Mode: CLUSTER, Addrs: []string{seed.Addr().String()}, MaxIdle: 1, MaxActive: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Close(pool)
+	// 订阅类命令固定到单个节点，退订后归还的连接被丢弃
+	psc := redigo.PubSubConn{Conn: pool.Get()}
+	psc.Subscribe("news")
+	fmt.Println(psc.Receive())
+	psc.Close()
+	fmt.Println(pool.Stats().IdleCount)
+	// 复用连接池时仍按键所在槽位路由
+	r := pool.Get()
+	defer r.Close()
+	fmt.Println(Slot("foo") >= CLUSTER_SLOTS/2)
+	fmt.Println(r.Do("SET", "foo", "bar"))
+	fmt.Println(high.Exists("foo"), low.Exists("foo"))
+	// Output:
+	// {subscribe news 1}
+	// 0
+	// true
+	// OK <nil>
+	// true false
+}
+
+func mustAtoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return n
+}
+
+func ExampleNewPool_clusterMultiKey() {
+	low, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer low.Close()
+	high, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer high.Close()
+	seed := slotsServer(low, high)
+	defer seed.Close()
+	pool, err := NewPool(context.Background(), Option{Mode: CLUSTER, Addrs: []string{seed.Addr().String()}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Close(pool)
+	r := pool.Get()
+	defer r.Close()
+	// 键位于不同槽位时按槽位拆分执行，结果按原顺序合并
+	fmt.Println(Slot("foo") >= CLUSTER_SLOTS/2, Slot("bar") >= CLUSTER_SLOTS/2)
+	fmt.Println(r.Do("MSET", "foo", "1", "bar", "2"))
+	fmt.Println(high.Exists("foo"), low.Exists("bar"))
+	fmt.Println(redigo.Strings(r.Do("MGET", "foo", "missing", "bar")))
+	fmt.Println(r.Do("EXISTS", "foo", "bar", "missing"))
+	fmt.Println(r.Do("DEL", "foo", "bar"))
+	// Output:
+	// true false
+	// OK <nil>
+	// true true
+	// [1  2] <nil>
+	// 2 <nil>
+	// 2 <nil>
+}
+
+// 模拟集群的种子节点，各节点平分槽位
+func slotsServer(nodes ...*miniredis.Miniredis) *server.Server {
+	seed, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	seed.Register("CLUSTER", func(c *server.Peer, cmd string, args []string) {
+		c.WriteLen(len(nodes))
+		for i, node := range nodes {
+			c.WriteLen(3)
+			c.WriteInt(i * CLUSTER_SLOTS / len(nodes))
+			c.WriteInt((i+1)*CLUSTER_SLOTS/len(nodes) - 1)
+			c.WriteLen(2)
+			c.WriteBulk(node.Host())
+			c.WriteInt(mustAtoi(node.Port()))
+		}
+	})
+	return seed
+}
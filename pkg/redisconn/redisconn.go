@@ -0,0 +1,287 @@
+package redisconn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aivencs/magic-box/pkg/trace"
+	"github.com/aivencs/magic-box/pkg/validate"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// 使用枚举限定连接方式
+type Mode string
+
+const (
+	STANDALONE Mode = "standalone" // 单节点
+	SENTINEL   Mode = "sentinel"   // 哨兵，自动发现主节点
+	CLUSTER    Mode = "cluster"    // 集群，按槽位路由
+	// 定义默认值
+	DEFAULT_MAXIDLE      = 20
+	DEFAULT_IDLE_TIMEOUT = 120 * time.Second
+	DEFAULT_MAXACTIVE    = 100
+	// 借出空闲连接时的检查，最近使用过的连接不检查
+	DEFAULT_CHECK_IDLE    = time.Minute
+	DEFAULT_CHECK_TIMEOUT = time.Second
+)
+
+func init() {
+	ctx := trace.WithTrace(context.Background(), "init-for-redisconn")
+	validate.InitValidate(ctx, "validator", validate.Option{})
+}
+
+// 建立连接时所用参数
+type Option struct {
+	Mode             Mode          `json:"mode" label:"连接方式" desc:"默认为单节点" validate:"omitempty,oneof=standalone sentinel cluster"`
+	Addrs            []string      `json:"addrs" label:"服务地址列表" desc:"单节点时使用第一个地址，哨兵时为哨兵地址，集群时为任意节点地址" validate:"required,min=1"`
+	MasterName       string        `json:"master_name" label:"主节点名称" desc:"哨兵使用"`
+	SentinelPassword string        `json:"sentinel_password" label:"哨兵密码"`
+	Auth             bool          `json:"auth" label:"是否鉴权" desc:"默认不鉴权"`
+	Username         string        `json:"username" label:"用户名"`
+	Password         string        `json:"password" label:"密码"`
+	DB               int           `json:"db" label:"数据库" desc:"集群不支持" validate:"gte=0"`
+	TLS              TLSOption     `json:"tls" label:"TLS参数"`
+	DialTimeout      time.Duration `json:"dial_timeout" label:"连接超时时间" desc:"为0时不限制" validate:"gte=0"`
+	ReadTimeout      time.Duration `json:"read_timeout" label:"读取超时时间" desc:"为0时不限制" validate:"gte=0"`
+	WriteTimeout     time.Duration `json:"write_timeout" label:"写入超时时间" desc:"为0时不限制" validate:"gte=0"`
+	MaxIdle          int           `json:"max_idle" label:"最大空闲链接数" desc:"默认20，不超过最大链接数" validate:"gte=0"`
+	IdleTimeout      time.Duration `json:"idle_timeout" label:"空闲超时时间" desc:"默认120秒" validate:"gte=0"`
+	MaxActive        int           `json:"max_active" label:"最大链接数" desc:"默认100" validate:"gte=0"`
+}
+
+// TLS参数
+type TLSOption struct {
+	Enable     bool   `json:"enable" label:"是否启用TLS" desc:"默认不启用"`
+	CAFile     string `json:"ca_file" label:"CA证书路径" desc:"为空时使用系统证书"`
+	CertFile   string `json:"cert_file" label:"客户端证书路径" desc:"双向认证使用"`
+	KeyFile    string `json:"key_file" label:"客户端私钥路径" desc:"双向认证使用"`
+	ServerName string `json:"server_name" label:"证书域名" desc:"为空时使用服务地址的主机名"`
+	SkipVerify bool   `json:"skip_verify" label:"跳过证书校验" desc:"默认不跳过"`
+}
+
+// 按连接方式创建连接池，未设置的连接池参数使用默认值
+func NewPool(ctx context.Context, option Option) (*redigo.Pool, error) {
+	message, err := validate.Work(ctx, option)
+	if err != nil {
+		return nil, errors.New(message)
+	}
+	ApplyDefault(&option)
+	if len(option.Username) > 0 && len(option.Password) == 0 && option.Auth {
+		return nil, errors.New("设置用户名时密码为必填项")
+	}
+	tlsOptions, err := tlsDialOptions(option.TLS)
+	if err != nil {
+		return nil, err
+	}
+	options := append(dialOptions(option), tlsOptions...)
+	switch option.Mode {
+	case STANDALONE, "":
+		return newPool(option, func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", option.Addrs[0], options...)
+		}, ping), nil
+	case SENTINEL:
+		if len(option.MasterName) == 0 {
+			return nil, errors.New("哨兵模式下主节点名称为必填项")
+		}
+		s := newSentinel(option, options, tlsOptions)
+		return newPool(option, s.dial, s.check), nil
+	case CLUSTER:
+		if option.DB != 0 {
+			return nil, errors.New("集群模式仅支持0号数据库")
+		}
+		c := newCluster(option, options)
+		pool := newPool(option, c.dial, nil)
+		clusters.Store(pool, c)
+		return pool, nil
+	default:
+		return nil, fmt.Errorf("不支持的连接方式: %s", option.Mode)
+	}
+}
+
+// 填充默认值，最大空闲链接数不超过最大链接数
+func ApplyDefault(option *Option) {
+	if option.MaxIdle == 0 {
+		option.MaxIdle = DEFAULT_MAXIDLE
+	}
+	if option.IdleTimeout == 0 {
+		option.IdleTimeout = DEFAULT_IDLE_TIMEOUT
+	}
+	if option.MaxActive == 0 {
+		option.MaxActive = DEFAULT_MAXACTIVE
+	}
+	if option.MaxIdle > option.MaxActive {
+		option.MaxIdle = option.MaxActive
+	}
+}
+
+func newPool(option Option, dial func() (redigo.Conn, error), test func(c redigo.Conn, t time.Time) error) *redigo.Pool {
+	return &redigo.Pool{
+		MaxIdle:      option.MaxIdle,
+		IdleTimeout:  option.IdleTimeout,
+		MaxActive:    option.MaxActive,
+		Wait:         true,
+		Dial:         dial,
+		TestOnBorrow: test,
+	}
+}
+
+// 连接节点时所用参数，鉴权与切换数据库在连接建立后完成
+func dialOptions(option Option) []redigo.DialOption {
+	options := []redigo.DialOption{
+		redigo.DialConnectTimeout(option.DialTimeout),
+		redigo.DialReadTimeout(option.ReadTimeout),
+		redigo.DialWriteTimeout(option.WriteTimeout),
+		// 为0时不发送SELECT
+		redigo.DialDatabase(option.DB),
+	}
+	if option.Auth {
+		// 设置用户名时使用Redis 6的ACL鉴权
+		if len(option.Username) > 0 {
+			options = append(options, redigo.DialUsername(option.Username))
+		}
+		options = append(options, redigo.DialPassword(option.Password))
+	}
+	return options
+}
+
+func tlsDialOptions(option TLSOption) ([]redigo.DialOption, error) {
+	if !option.Enable {
+		return nil, nil
+	}
+	config := &tls.Config{
+		ServerName:         option.ServerName,
+		InsecureSkipVerify: option.SkipVerify,
+	}
+	if len(option.CAFile) > 0 {
+		data, err := os.ReadFile(option.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errors.New("CA证书格式错误")
+		}
+	}
+	if len(option.CertFile) > 0 || len(option.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(option.CertFile, option.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端证书失败: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return []redigo.DialOption{
+		redigo.DialUseTLS(true),
+		redigo.DialTLSConfig(config),
+		redigo.DialTLSSkipVerify(option.SkipVerify),
+	}, nil
+}
+
+// 借出时检查，限制等待时间，避免服务无响应时阻塞获取连接
+func ping(c redigo.Conn, t time.Time) error {
+	if time.Since(t) < DEFAULT_CHECK_IDLE {
+		return nil
+	}
+	_, err := redigo.DoWithTimeout(c, DEFAULT_CHECK_TIMEOUT, "PING")
+	return err
+}
+
+// 连接池统计，集群模式下为全部节点之和
+type Stats struct {
+	ActiveCount  int           `json:"active_count" label:"链接数"`
+	IdleCount    int           `json:"idle_count" label:"空闲链接数"`
+	WaitCount    int64         `json:"wait_count" label:"等待次数"`
+	WaitDuration time.Duration `json:"wait_duration" label:"等待总时长"`
+}
+
+// 集群模式下连接池对应的节点信息
+var clusters sync.Map
+
+// 获取连接池统计
+func StatsOf(pool *redigo.Pool) Stats {
+	if c, ok := clusters.Load(pool); ok {
+		var stats Stats
+		for _, p := range c.(*cluster).pools() {
+			stats.add(p.Stats())
+		}
+		return stats
+	}
+	var stats Stats
+	stats.add(pool.Stats())
+	return stats
+}
+
+func (s *Stats) add(stats redigo.PoolStats) {
+	s.ActiveCount += stats.ActiveCount
+	s.IdleCount += stats.IdleCount
+	s.WaitCount += stats.WaitCount
+	s.WaitDuration += stats.WaitDuration
+}
+
+// 节点及其连接池
+type Node struct {
+	Addr string
+	Pool *redigo.Pool
+}
+
+// 各节点的连接池，集群模式下为持有槽位的节点，其他模式下为连接池本身且地址为空
+func Nodes(pool *redigo.Pool) ([]Node, error) {
+	v, ok := clusters.Load(pool)
+	if !ok {
+		return []Node{{Pool: pool}}, nil
+	}
+	c := v.(*cluster)
+	if err := c.ready(); err != nil {
+		return nil, err
+	}
+	nodes := []Node{}
+	for _, addr := range c.masters() {
+		nodes = append(nodes, Node{Addr: addr, Pool: c.pool(addr)})
+	}
+	return nodes, nil
+}
+
+// 关闭连接池，集群模式下同时关闭各节点的连接池
+//
+// 已借出的连接在归还时关闭，关闭后获取的连接均返回错误
+func Close(pool *redigo.Pool) error {
+	err := pool.Close()
+	if v, ok := clusters.LoadAndDelete(pool); ok {
+		if e := v.(*cluster).close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// 健康检查，集群模式下检查全部节点
+func Health(ctx context.Context, pool *redigo.Pool) error {
+	nodes, err := Nodes(pool)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if err := health(ctx, node.Pool); err != nil {
+			if len(node.Addr) > 0 {
+				return fmt.Errorf("节点%s不可用: %w", node.Addr, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func health(ctx context.Context, pool *redigo.Pool) error {
+	r, err := pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = redigo.DoContext(r, ctx, "PING")
+	return err
+}
@@ -0,0 +1,127 @@
+package redisconn
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// 定义默认值
+	DEFAULT_SENTINEL_TIMEOUT  = time.Second
+	DEFAULT_SENTINEL_INTERVAL = time.Second
+)
+
+// 通过哨兵发现主节点，主从切换后新建的连接指向新的主节点
+type sentinel struct {
+	option  Option
+	dialer  []redigo.DialOption // 连接数据节点
+	query   []redigo.DialOption // 连接哨兵
+	addrs   []string
+	master  string
+	checked time.Time
+	mu      sync.Mutex
+}
+
+// 哨兵与数据节点使用相同的TLS参数
+func newSentinel(option Option, dialer []redigo.DialOption, tlsOptions []redigo.DialOption) *sentinel {
+	query := []redigo.DialOption{
+		redigo.DialConnectTimeout(DEFAULT_SENTINEL_TIMEOUT),
+		redigo.DialReadTimeout(DEFAULT_SENTINEL_TIMEOUT),
+		redigo.DialWriteTimeout(DEFAULT_SENTINEL_TIMEOUT),
+	}
+	if len(option.SentinelPassword) > 0 {
+		query = append(query, redigo.DialPassword(option.SentinelPassword))
+	}
+	return &sentinel{
+		option: option,
+		dialer: dialer,
+		query:  append(query, tlsOptions...),
+		addrs:  append([]string{}, option.Addrs...),
+	}
+}
+
+// 获取主节点地址，间隔内重复调用时使用已知地址
+func (s *sentinel) masterAddr(force bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !force && len(s.master) > 0 && time.Since(s.checked) < DEFAULT_SENTINEL_INTERVAL {
+		return s.master, nil
+	}
+	var lastErr error
+	for i, addr := range s.addrs {
+		master, err := s.lookup(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// 优先使用可用的哨兵
+		s.addrs[0], s.addrs[i] = s.addrs[i], s.addrs[0]
+		s.master = master
+		s.checked = time.Now()
+		return master, nil
+	}
+	return "", fmt.Errorf("获取主节点%s失败: %w", s.option.MasterName, lastErr)
+}
+
+func (s *sentinel) lookup(addr string) (string, error) {
+	c, err := redigo.Dial("tcp", addr, s.query...)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	reply, err := redigo.Strings(c.Do("SENTINEL", "get-master-addr-by-name", s.option.MasterName))
+	if err != nil {
+		return "", err
+	}
+	if len(reply) != 2 {
+		return "", errors.New("主节点不存在")
+	}
+	return net.JoinHostPort(reply[0], reply[1]), nil
+}
+
+// 连接主节点，节点角色不是主节点时重新发现
+func (s *sentinel) dial() (redigo.Conn, error) {
+	addr, err := s.masterAddr(false)
+	if err != nil {
+		return nil, err
+	}
+	c, err := redigo.Dial("tcp", addr, s.dialer...)
+	if err == nil {
+		if err = s.check(c, time.Time{}); err == nil {
+			return c, nil
+		}
+		c.Close()
+	}
+	if addr, err = s.masterAddr(true); err != nil {
+		return nil, err
+	}
+	c, err = redigo.Dial("tcp", addr, s.dialer...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(c, time.Time{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// 借出连接时确认仍是主节点，主从切换后旧连接被丢弃
+func (s *sentinel) check(c redigo.Conn, t time.Time) error {
+	reply, err := redigo.Values(c.Do("ROLE"))
+	if err != nil {
+		return err
+	}
+	if len(reply) == 0 {
+		return errors.New("节点角色未知")
+	}
+	if role, _ := redigo.String(reply[0], nil); role != "master" {
+		return fmt.Errorf("节点已不是主节点: %s", role)
+	}
+	return nil
+}
@@ -6,10 +6,12 @@ import (
 	"log"
 
 	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
+	"github.com/aivencs/magic-box/pkg/validate"
 )
 
 func ExampleGet() {
-	ctx := context.WithValue(context.Background(), "trace", "r001")
+	ctx := trace.WithTrace(context.Background(), "r001")
 	for i := 0; i < 2; i++ {
 		initError := InitRequest(ctx, "resty", Option{
 			LogType: logger.Zap,
@@ -23,7 +25,7 @@ func ExampleGet() {
 		if initError.Code != logger.SUCCESS {
 			log.Fatal(initError.Label)
 		}
-		res, err := request.Get(ctx, Param{
+		res, err := Get(ctx, Param{
 			Link:    "https://www.example.com",
 			Method:  GET,
 			Timeout: 5,
@@ -36,7 +38,7 @@ func ExampleGet() {
 }
 
 func ExamplePost() {
-	ctx := context.WithValue(context.Background(), "trace", "r002")
+	ctx := trace.WithTrace(context.Background(), "r002")
 	for i := 0; i < 2; i++ {
 		initError := InitRequest(ctx, "resty", Option{
 			LogType: logger.Zap,
@@ -50,7 +52,7 @@ func ExamplePost() {
 		if initError.Code != logger.SUCCESS {
 			log.Fatal(initError.Label)
 		}
-		res, err := request.Post(ctx, Param{
+		res, err := Post(ctx, Param{
 			Link:    "https://www.example.com",
 			Method:  POST,
 			Timeout: 5,
@@ -61,3 +63,70 @@ func ExamplePost() {
 		fmt.Println("status: ", res.StatusCode, "err: ", err)
 	}
 }
+
+func ExampleNew() {
+	ctx := trace.WithTrace(context.Background(), "r003")
+	c, err := New(ctx, RESTY, Option{
+		LogType: logger.Zap,
+		LogOption: logger.Option{
+			Application: "resty",
+			Env:         "product",
+			Label:       "request",
+			Encode:      logger.Json,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 请求对象使用独立的日志对象，不替换默认日志对象
+	fmt.Println(c != nil, logger.Default() == nil)
+	// 不再使用时关闭，注册的对象在进程退出时由 lifecycle 关闭
+	fmt.Println(c.Close(ctx))
+	// Output:
+	// true true
+	// <nil>
+}
+
+// 记录调用方式的请求对象
+type recorder struct{}
+
+func (recorder) Get(ctx context.Context, param Param) (Result, error) {
+	return Result{Text: "Get " + string(param.Method)}, nil
+}
+
+func (recorder) Post(ctx context.Context, param Param) (Result, error) {
+	return Result{Text: "Post " + string(param.Method)}, nil
+}
+
+func (recorder) Close(ctx context.Context) error {
+	return nil
+}
+
+func ExampleRegister() {
+	ctx := trace.WithTrace(context.Background(), "r004")
+	// 创建请求对象时不替换已注册的默认校验对象
+	v, err := validate.New(ctx, validate.Validator, validate.Option{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	validate.Register(validate.DEFAULT_INSTANCE, v)
+	c, err := New(ctx, RESTY, Option{
+		LogType:   logger.Zap,
+		LogOption: logger.Option{Application: "resty", Env: "product", Label: "request"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close(ctx)
+	fmt.Println(validate.Default() == v)
+	// 包级函数调用默认对象对应的方法
+	Register(DEFAULT_INSTANCE, recorder{})
+	res, _ := Get(ctx, Param{})
+	fmt.Println(res.Text)
+	res, _ = Post(ctx, Param{})
+	fmt.Println(res.Text)
+	// Output:
+	// true
+	// Get GET
+	// Post POST
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 	"sync"
 	"time"
 	"unicode/utf8"
 
+	"github.com/aivencs/magic-box/pkg/lifecycle"
+	"github.com/aivencs/magic-box/pkg/limiter"
 	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 	"github.com/go-resty/resty/v2"
 )
@@ -30,21 +34,24 @@ const (
 	DEFAULT_TIEOUT = 10
 )
 
-var once sync.Once
-
 // 定义全局请求对象
-var request Request
+var instances = map[string]Request{}
+var mu sync.RWMutex
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
+
+var baseOnce sync.Once
 
-func initBaseComponent(name logger.SupportType, option logger.Option) {
-	ctx := context.WithValue(context.Background(), "trace", "init-for-request")
-	validate.InitValidate(ctx, "validator", validate.Option{})
-	logger.InitErrorCode()
-	logger.InitLogger(ctx, name, option)
+// 初始化错误码，仅执行一次；默认校验对象在首次使用时创建，不替换已有对象
+func initBaseComponent() {
+	baseOnce.Do(logger.InitErrorCode)
 }
 
 type Request interface {
 	Get(ctx context.Context, param Param) (Result, error)
 	Post(ctx context.Context, param Param) (Result, error)
+	Close(ctx context.Context) error
 }
 
 // 请求结果
@@ -59,11 +66,17 @@ type Result struct {
 type Option struct {
 	LogType   logger.SupportType `json:"log_type" label:"日志组件" validate:"required"`
 	LogOption logger.Option      `json:"log_option" label:"日志组件参数" validate:"required"`
+	Throttle  limiter.Limiter    `json:"-" label:"客户端限流" desc:"按网址的主机名限流，默认不限流"`
+	MaxWait   time.Duration      `json:"max_wait" label:"限流最长等待时间" desc:"为0时超限立即返回"`
 }
 
 // 结构体
 // 基于Resty
-type RestyRequest struct{}
+type RestyRequest struct {
+	log      logger.Logger // 独立的日志对象，不影响默认日志对象
+	throttle limiter.Limiter
+	maxWait  time.Duration
+}
 
 // 请求参数
 type Param struct {
@@ -76,41 +89,71 @@ type Param struct {
 	EnableHeader     bool       `json:"enable_header" label:"根据网址设置请求头基本参数" desc:"默认不开启"`
 }
 
-// 初始化对象
+// 初始化默认对象，重复调用时替换默认对象
 func InitRequest(ctx context.Context, name SupportType, option Option) logger.ErrorCode {
-	c := request
-	var err error
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return logger.ErrorCode{Code: logger.PVERROR, Level: logger.ERROR, Label: err.Error(), Name: err.Error()}
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return logger.GetDefaultErc()
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Request, error) {
+	initBaseComponent()
 	message, err := validate.Work(ctx, &option)
 	if err != nil {
-		return logger.ErrorCode{Code: logger.PVERROR, Level: logger.ERROR, Label: message, Name: message}
+		return nil, errors.New(message)
 	}
-	initBaseComponent(option.LogType, option.LogOption)
-	once.Do(func() {
-		c = RequestFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		request = c
-	})
+	c, err := RequestFactory(ctx, name, option)
 	if err != nil {
-		return logger.ErrorCode{Code: logger.PVERROR, Level: logger.ERROR, Label: err.Error(), Name: err.Error()}
+		return nil, fmt.Errorf("初始化失败: %w", err)
 	}
-	return logger.GetDefaultErc()
+	return c, nil
+}
+
+// 注册命名对象，名称已存在时替换并关闭原对象，进程退出时由 lifecycle 关闭
+func Register(key string, c Request) {
+	mu.Lock()
+	instances[key] = c
+	mu.Unlock()
+	lifecycle.Register("request:"+key, c)
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Request {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Request {
+	return Use(DEFAULT_INSTANCE)
 }
 
 // 抽象工厂
-func RequestFactory(ctx context.Context, name SupportType, option Option) Request {
+func RequestFactory(ctx context.Context, name SupportType, option Option) (Request, error) {
 	switch name {
 	case RESTY:
 		return NewRestyRequest(ctx, option)
 	default:
-		return NewRestyRequest(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %s", name)
 	}
 }
 
-// 创建基于Resty的请求对象
-func NewRestyRequest(ctx context.Context, option Option) Request {
-	return &RestyRequest{}
+// 创建基于Resty的请求对象，按日志组件参数创建独立的日志对象
+func NewRestyRequest(ctx context.Context, option Option) (Request, error) {
+	log, err := logger.New(ctx, option.LogType, option.LogOption)
+	if err != nil {
+		return nil, fmt.Errorf("初始化日志组件失败: %w", err)
+	}
+	return &RestyRequest{
+		log:      log,
+		throttle: option.Throttle,
+		maxWait:  option.MaxWait,
+	}, nil
 }
 
 func (c *RestyRequest) Get(ctx context.Context, param Param) (Result, error) {
@@ -121,19 +164,29 @@ func (c *RestyRequest) Post(ctx context.Context, param Param) (Result, error) {
 	return c.work(ctx, param)
 }
 
+// 关闭独立的日志对象
+func (c *RestyRequest) Close(ctx context.Context) error {
+	return c.log.Close(ctx)
+}
+
 func (c *RestyRequest) work(ctx context.Context, param Param) (Result, error) {
 	var response *resty.Response
 	var err error
 	// 参数校验
 	message, err := validate.Work(ctx, param)
 	if err != nil {
-		return Result{ErrorCode: logger.GetErc(logger.PVERROR, "")}, errors.New(message)
+		e := logger.NewError(logger.PVERROR, message)
+		return Result{ErrorCode: e.ErrorCode()}, e
 	}
 	// 前期准备
+	ctx = trace.EnsureTrace(ctx)
 	serviceSafeString, _ := url.Parse(param.Link)
+	if err := c.wait(ctx, serviceSafeString.Host); err != nil {
+		return Result{ErrorCode: logger.ErcOf(err)}, err
+	}
 	client := resty.New()
 	// 设置追踪编码
-	client.SetHeaders(map[string]string{"X-REQUEST-ID": ctx.Value("trace").(string)})
+	client.SetHeaders(map[string]string{"X-REQUEST-ID": trace.TraceFrom(ctx)})
 	// 参数项的应用
 	if param.Timeout == 0 {
 		param.Timeout = DEFAULT_TIEOUT
@@ -144,7 +197,7 @@ func (c *RestyRequest) work(ctx context.Context, param Param) (Result, error) {
 	}
 	if param.EnableHeader {
 		client.SetHeaders(map[string]string{
-			"X-REQUEST-ID": ctx.Value("trace").(string),
+			"X-REQUEST-ID": trace.TraceFrom(ctx),
 			"Host":         serviceSafeString.Host,
 			"Referer":      serviceSafeString.Host,
 			"User-Agent":   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/92.0.4515.159 Safari/537.36",
@@ -169,23 +222,21 @@ func (c *RestyRequest) work(ctx context.Context, param Param) (Result, error) {
 	duration := time.Since(startT).Milliseconds()
 	// 请求结果处理
 	if err != nil {
-		erc := logger.GetErc(logger.DVERROR, "请求时发生错误")
+		e := logger.WrapError(err, logger.DVERROR, "请求时发生错误")
 		if strings.Contains(err.Error(), "Client.Timeout ") {
-			erc = logger.GetErc(logger.TIMEOUT, "")
+			e = logger.WrapError(err, logger.TIMEOUT, "")
 		}
-		logger.Info(ctx, logger.Message{
-			Text:      erc.Label,
-			Label:     ctx.Value("label").(string),
-			Traceback: err.Error(),
+		c.log.Info(ctx, logger.Message{
+			Text:  e.Label,
+			Label: trace.LabelFrom(ctx),
+			Error: e,
 			Attr: logger.Attr{
 				Monitor: logger.Monitor{
-					Level:           erc.Level,
-					Code:            erc.Code,
 					ProcessDuration: duration,
 				},
 			},
 		})
-		return Result{ErrorCode: erc}, errors.New(erc.Label)
+		return Result{ErrorCode: e.ErrorCode()}, e
 	}
 	// 状态码处理
 	erc := logger.GetDefaultErc()
@@ -193,17 +244,17 @@ func (c *RestyRequest) work(ctx context.Context, param Param) (Result, error) {
 		switch response.RawResponse.StatusCode {
 		case 429:
 			erc = logger.GetErc(logger.LIMITERROR, "")
-			err = errors.New(erc.Label)
+			err = logger.NewError(erc.Code, erc.Label)
 		case 404:
 			erc = logger.GetErc(logger.CHECK, "资源不存在")
-			err = errors.New(erc.Label)
+			err = logger.NewError(erc.Code, erc.Label)
 		case 200:
 			err = nil
 		case 201:
 			err = nil
 		default:
 			erc = logger.GetErc(logger.STATUSERROR, "")
-			err = errors.New(erc.Label)
+			err = logger.NewError(erc.Code, erc.Label)
 		}
 	}
 	// 构造结果并返回
@@ -215,14 +266,38 @@ func (c *RestyRequest) work(ctx context.Context, param Param) (Result, error) {
 	}, err
 }
 
+// 按主机名限流，超限时在最长等待时间内等待放行
+func (c *RestyRequest) wait(ctx context.Context, host string) error {
+	if c.throttle == nil {
+		return nil
+	}
+	deadline := time.Now().Add(c.maxWait)
+	for {
+		result, err := c.throttle.Allow(ctx, host)
+		if err != nil || result.Allowed {
+			return nil
+		}
+		if result.RetryAfter <= 0 || time.Now().Add(result.RetryAfter).After(deadline) {
+			return logger.NewError(logger.LIMITERROR, "")
+		}
+		timer := time.NewTimer(result.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return logger.WrapError(ctx.Err(), logger.LIMITERROR, "")
+		case <-timer.C:
+		}
+	}
+}
+
 // 暴露给外部调用
 func Get(ctx context.Context, param Param) (Result, error) {
 	param.Method = GET
-	return request.Get(ctx, param)
+	return Default().Get(ctx, param)
 }
 
 // 暴露给外部调用
 func Post(ctx context.Context, param Param) (Result, error) {
 	param.Method = POST
-	return request.Get(ctx, param)
+	return Default().Post(ctx, param)
 }
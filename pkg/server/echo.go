@@ -5,14 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/aivencs/magic-box/pkg/kit"
+	"github.com/aivencs/magic-box/pkg/limiter"
 	"github.com/aivencs/magic-box/pkg/logger"
+	"github.com/aivencs/magic-box/pkg/trace"
 	"github.com/aivencs/magic-box/pkg/validate"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -31,14 +35,18 @@ const (
 	DELETE MethodType = "DELETE"
 	PUT    MethodType = "PUT"
 	// 定义默认值
-	DEFAULT_HOST = ":"
+	DEFAULT_HOST       = ":"
+	DEFAULT_LEVEL_PATH = "/logger/level"
 )
 
-var server Server
-var once sync.Once
+var instances = map[string]Server{}
+var mu sync.RWMutex
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
 
 func init() {
-	ctx := context.WithValue(context.Background(), "trace", "init-for-server")
+	ctx := trace.WithTrace(context.Background(), "init-for-server")
 	validate.InitValidate(ctx, "validator", validate.Option{})
 }
 
@@ -58,34 +66,62 @@ type Option struct {
 	Port               int    `json:"port" label:"端口号" validate:"required,min=3000,max=10000"`
 	DisableMiddCors    bool   `json:"disable_midd_cors" label:"cors中间件开关" desc:"默认开启"`
 	DisableMiddRecover bool   `json:"disable_midd_recover" label:"recover中间件开关" desc:"默认开启"`
+	EnableLevelRouter  bool   `json:"enable_level_router" label:"日志级别接口开关" desc:"默认关闭，开启后可在运行时调整日志级别"`
+	LevelPath          string `json:"level_path" label:"日志级别接口路径" desc:"默认为/logger/level"`
 }
 
+// 初始化默认对象，重复调用时替换默认对象
 func InitServer(ctx context.Context, name SupportType, option Option) error {
-	c := server
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Server, error) {
 	message, err := validate.Work(ctx, option)
 	if err != nil {
-		return errors.New(message)
+		return nil, errors.New(message)
 	}
-	once.Do(func() {
-		c = ServerFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		server = c
-	})
-	return err
+	c, err := ServerFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
 }
 
-func ServerFactory(ctx context.Context, name SupportType, option Option) Server {
+// 注册命名对象，名称已存在时替换
+func Register(key string, c Server) {
+	mu.Lock()
+	defer mu.Unlock()
+	instances[key] = c
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Server {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象
+func Default() Server {
+	return Use(DEFAULT_INSTANCE)
+}
+
+func ServerFactory(ctx context.Context, name SupportType, option Option) (Server, error) {
 	switch name {
 	case SERVER_ECHO:
 		return NewEchoServer(ctx, option)
 	default:
-		return NewEchoServer(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %s", name)
 	}
 }
 
-func NewEchoServer(ctx context.Context, option Option) Server {
+func NewEchoServer(ctx context.Context, option Option) (Server, error) {
 	svr := echo.New()
 	if !option.DisableMiddCors {
 		svr.Use(middleware.CORS())
@@ -93,6 +129,14 @@ func NewEchoServer(ctx context.Context, option Option) Server {
 	if !option.DisableMiddRecover {
 		svr.Use(middleware.Recover())
 	}
+	if option.EnableLevelRouter {
+		if len(option.LevelPath) == 0 {
+			option.LevelPath = DEFAULT_LEVEL_PATH
+		}
+		handler := echo.WrapHandler(logger.LevelHandler())
+		svr.GET(option.LevelPath, handler)
+		svr.PUT(option.LevelPath, handler)
+	}
 	if len(option.Host) == 0 {
 		option.Host = DEFAULT_HOST
 	} else {
@@ -102,7 +146,7 @@ func NewEchoServer(ctx context.Context, option Option) Server {
 		Kernel: svr,
 		Port:   option.Port,
 		Host:   option.Host,
-	}
+	}, nil
 }
 
 func (c *EchoServer) Work() {
@@ -132,11 +176,11 @@ func (c *EchoServer) AddRouter(payload RouterPayload, h echo.HandlerFunc, m ...e
 }
 
 func Work() {
-	server.Work()
+	Default().Work()
 }
 
 func AddRouter(payload RouterPayload, h echo.HandlerFunc, m ...echo.MiddlewareFunc) {
-	server.AddRouter(payload, h, m...)
+	Default().AddRouter(payload, h, m...)
 }
 
 type bodyDumpResponseWriter struct {
@@ -153,20 +197,41 @@ type ServerResponse struct {
 }
 
 func EmptyHandler(c echo.Context) error {
+	message, _ := c.Get("message").(string)
 	res := ServerResponse{
 		Code:    logger.GetErc(logger.PVERROR, "").Code,
-		Trace:   c.Get("trace").(string),
-		Message: c.Get("message").(string),
+		Trace:   trace.TraceFrom(ContextFrom(c)),
+		Message: message,
 		Result:  nil,
 	}
 
 	return c.JSONPretty(http.StatusOK, res, "")
 }
 
+// 根据结果与错误构建响应，错误的信息码与描述会写入响应
+func Respond(c echo.Context, result interface{}, err error) error {
+	erc := logger.ErcOf(err)
+	res := ServerResponse{
+		Code:    erc.Code,
+		Trace:   trace.TraceFrom(ContextFrom(c)),
+		Message: erc.Label,
+		Result:  result,
+	}
+	return c.JSONPretty(http.StatusOK, res, "")
+}
+
 type Header struct {
 	X_REQUEST_ID string `json:"X-REQUEST-ID" label:"追踪编码" validate:"required,min=16,max=100"`
 }
 
+// 获取中间件设置的Context，不存在时返回带有新追踪编码的Context
+func ContextFrom(c echo.Context) context.Context {
+	if ctx, ok := c.Get("context").(context.Context); ok {
+		return ctx
+	}
+	return trace.WithTrace(c.Request().Context(), "")
+}
+
 // 基础日志中间件
 func loggerBase(next echo.HandlerFunc, inp bool, oup bool) echo.HandlerFunc {
 	return func(c echo.Context) (err error) {
@@ -179,10 +244,10 @@ func loggerBase(next echo.HandlerFunc, inp bool, oup bool) echo.HandlerFunc {
 			header.X_REQUEST_ID = ids[0]
 		}
 		// 创建新的Context
-		ctx := context.WithValue(context.Background(), "trace", header.X_REQUEST_ID)
-		ctx = context.WithValue(ctx, "label", c.Request().URL.Path)
+		ctx := trace.WithTrace(context.Background(), header.X_REQUEST_ID)
+		ctx = trace.WithLabel(ctx, c.Request().URL.Path)
 		// 设置框架的Context
-		c.Set("trace", header.X_REQUEST_ID)
+		c.Set("trace", trace.TraceFrom(ctx))
 		c.Set("label", c.Request().URL.Path)
 		c.Set("context", ctx)
 		// 校验追踪编码
@@ -219,6 +284,11 @@ func loggerBase(next echo.HandlerFunc, inp bool, oup bool) echo.HandlerFunc {
 		if oup {
 			output["response"] = response
 		}
+		// 响应不符合统一格式时不设置级别
+		var level logger.LoggerLevel
+		if response.Code != 0 {
+			level = logger.GetErc(response.Code, "").Level
+		}
 		logger.Info(ctx, logger.Message{
 			Text:  response.Message,
 			Label: c.Request().URL.Path,
@@ -227,7 +297,7 @@ func loggerBase(next echo.HandlerFunc, inp bool, oup bool) echo.HandlerFunc {
 					Final:           true,
 					ProcessDuration: duration,
 					Code:            response.Code,
-					Level:           logger.GetErc(response.Code, "").Level,
+					Level:           level,
 				},
 				Inp: input,
 				Oup: output,
@@ -237,6 +307,42 @@ func loggerBase(next echo.HandlerFunc, inp bool, oup bool) echo.HandlerFunc {
 	}
 }
 
+// 限流时获取键名的方法
+type KeyFunc func(c echo.Context) string
+
+// 限流中间件，超限时返回429与超限信息码；keyFunc 为空时按客户端IP限流
+//
+// 限流对象出错时放行
+func RateLimit(l limiter.Limiter, keyFunc KeyFunc) echo.MiddlewareFunc {
+	if keyFunc == nil {
+		keyFunc = func(c echo.Context) string {
+			return c.RealIP()
+		}
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			result, err := l.Allow(ContextFrom(c), keyFunc(c))
+			if err != nil {
+				return next(c)
+			}
+			header := c.Response().Header()
+			header.Set("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+			header.Set("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+			if result.Allowed {
+				return next(c)
+			}
+			retry := int64(math.Ceil(result.RetryAfter.Seconds()))
+			header.Set("Retry-After", strconv.FormatInt(retry, 10))
+			erc := logger.GetErc(logger.LIMITERROR, "")
+			return c.JSONPretty(http.StatusTooManyRequests, ServerResponse{
+				Code:    erc.Code,
+				Trace:   trace.TraceFrom(ContextFrom(c)),
+				Message: erc.Label,
+			}, "")
+		}
+	}
+}
+
 // 不带输入输出的日志中间件
 func LoggerNormal(next echo.HandlerFunc) echo.HandlerFunc {
 	return loggerBase(next, false, false)
@@ -0,0 +1,82 @@
+// 此包用于在 Context 中传递追踪编码与别称
+package trace
+
+import (
+	"context"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// 使用未导出的类型作为键，避免与其他包冲突
+type traceKey struct{}
+type labelKey struct{}
+
+const (
+	// 兼容旧版本直接使用字符串作为键的写法
+	LEGACY_TRACE_KEY = "trace"
+	LEGACY_LABEL_KEY = "label"
+)
+
+// 生成追踪编码
+func NewTrace() string {
+	return strings.ReplaceAll(uuid.NewV4().String(), "-", "")
+}
+
+// 设置追踪编码，为空时自动生成
+func WithTrace(ctx context.Context, trace string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(trace) == 0 {
+		trace = NewTrace()
+	}
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// 获取追踪编码，不存在时返回空字符串
+func TraceFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if value, ok := ctx.Value(traceKey{}).(string); ok {
+		return value
+	}
+	if value, ok := ctx.Value(LEGACY_TRACE_KEY).(string); ok {
+		return value
+	}
+	return ""
+}
+
+// 确保 Context 中存在追踪编码，不存在时自动生成
+func EnsureTrace(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Value(traceKey{}).(string); ok {
+		return ctx
+	}
+	return WithTrace(ctx, TraceFrom(ctx))
+}
+
+// 设置别称
+func WithLabel(ctx context.Context, label string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+// 获取别称，不存在时返回空字符串
+func LabelFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if value, ok := ctx.Value(labelKey{}).(string); ok {
+		return value
+	}
+	if value, ok := ctx.Value(LEGACY_LABEL_KEY).(string); ok {
+		return value
+	}
+	return ""
+}
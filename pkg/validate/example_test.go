@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+
+	"github.com/aivencs/magic-box/pkg/trace"
 )
 
 type Users struct {
@@ -28,10 +30,10 @@ func ExampleValidator() {
 		Email:   "abcfoxmail@foxmail.com",
 		Content: "<a>aps<a>",
 	}
-	ctx := context.WithValue(context.Background(), "trace", "v001")
+	ctx := trace.WithTrace(context.Background(), "v001")
 	err := InitValidate(ctx, "validator", Option{})
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println(validate.Work(ctx, users))
+	fmt.Println(Work(ctx, users))
 }
@@ -3,7 +3,6 @@ package validate
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"reflect"
 	"sync"
@@ -19,8 +18,11 @@ const (
 )
 
 // 定义全局配置对象
-var validate Validate
-var once sync.Once
+var instances = map[string]Validate{}
+var mu sync.RWMutex
+
+// 默认对象的名称
+const DEFAULT_INSTANCE = "default"
 
 // 抽象接口
 type Validate interface {
@@ -30,27 +32,59 @@ type Validate interface {
 // 初始化时所用参数
 type Option struct{}
 
-// 初始化对象
+// 初始化默认对象，重复调用时替换默认对象
 func InitValidate(ctx context.Context, name SupportType, option Option) error {
-	c := validate
-	var err error
-	once.Do(func() {
-		c = ValidateFactory(ctx, name, option)
-		if c == nil {
-			err = errors.New("初始化失败")
-		}
-		validate = c
-	})
-	return err
+	c, err := New(ctx, name, option)
+	if err != nil {
+		return err
+	}
+	Register(DEFAULT_INSTANCE, c)
+	return nil
+}
+
+// 创建对象，可同时创建多个互不影响的对象
+func New(ctx context.Context, name SupportType, option Option) (Validate, error) {
+	c, err := ValidateFactory(ctx, name, option)
+	if err != nil {
+		return nil, fmt.Errorf("初始化失败: %w", err)
+	}
+	return c, nil
+}
+
+// 注册命名对象，名称已存在时替换
+func Register(key string, c Validate) {
+	mu.Lock()
+	defer mu.Unlock()
+	instances[key] = c
+}
+
+// 获取命名对象，不存在时返回nil
+func Use(key string) Validate {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instances[key]
+}
+
+// 获取默认对象，未初始化时使用默认参数创建
+func Default() Validate {
+	if c := Use(DEFAULT_INSTANCE); c != nil {
+		return c
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := instances[DEFAULT_INSTANCE]; !ok {
+		instances[DEFAULT_INSTANCE], _ = NewValidator(context.Background(), Option{})
+	}
+	return instances[DEFAULT_INSTANCE]
 }
 
 // 抽象工厂
-func ValidateFactory(ctx context.Context, name SupportType, option Option) Validate {
+func ValidateFactory(ctx context.Context, name SupportType, option Option) (Validate, error) {
 	switch name {
 	case Validator:
 		return NewValidator(ctx, option)
 	default:
-		return NewValidator(ctx, option)
+		return nil, fmt.Errorf("不支持的类型: %s", name)
 	}
 }
 
@@ -61,14 +95,14 @@ type TheValidator struct {
 }
 
 // 创建基于的对象
-func NewValidator(ctx context.Context, option Option) Validate {
+func NewValidator(ctx context.Context, option Option) (Validate, error) {
 	v := validator.New()
 	// 将label作为字段名称
 	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		name := fld.Tag.Get("label")
 		return name
 	})
-	return &TheValidator{Kernel: v}
+	return &TheValidator{Kernel: v}, nil
 }
 
 func (c *TheValidator) Work(ctx context.Context, payload interface{}) (string, error) {
@@ -131,5 +165,5 @@ func (c *TheValidator) Work(ctx context.Context, payload interface{}) (string, e
 }
 
 func Work(ctx context.Context, payload interface{}) (string, error) {
-	return validate.Work(ctx, payload)
+	return Default().Work(ctx, payload)
 }